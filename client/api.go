@@ -0,0 +1,237 @@
+package client
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kparkins/yarbit/database"
+)
+
+const (
+	ApiRouteAddPeer      = "/node/peer"
+	ApiRouteSync         = "/node/sync"
+	ApiRouteHeaders      = "/node/headers"
+	ApiRouteAddTx        = "/tx/add"
+	ApiRouteStatus       = "/node/status"
+	ApiRouteListPeers    = "/node/peers"
+	ApiRouteNodeInfo     = "/node/info"
+	ApiRouteListBalances = "/balances/list"
+	ApiRouteGetBalance   = "/balances/{account}"
+	ApiRouteEvents       = "/events"
+	ApiRouteListBlocks   = "/blocks"
+	ApiRouteGetBlock     = "/blocks/{id}"
+	ApiRouteGetTx        = "/tx/{hash}"
+	ApiRouteAccountTxs   = "/accounts/{account}/txs"
+	ApiRouteExplorer     = "/explorer/"
+	ApiRouteMetrics      = "/metrics"
+	ApiRouteMiningWork   = "/mining/template"
+	ApiRouteMiningSubmit = "/mining/submit"
+	ApiRouteDevMine      = "/dev/mine"
+	ApiRouteDevTime      = "/dev/time"
+	ApiRouteInventory    = "/gossip/inv"
+	ApiRoutePendingTxs   = "/tx/pending"
+	ApiRouteGetPendingTx = "/tx/pending/{hash}"
+	ApiRouteRpc          = "/rpc"
+
+	ApiQueryParamAfter   = "after"
+	ApiQueryParamHeight  = "height"
+	ApiQueryParamTypes   = "types"
+	ApiQueryParamAccount = "account"
+	ApiQueryParamLimit   = "limit"
+	ApiQueryParamStream  = "stream"
+)
+
+const HeaderApiKey = "X-Api-Key"
+
+const (
+	ProtocolHttp  = "http"
+	ProtocolHttps = "https"
+)
+
+type PeerNode struct {
+	IpAddress   string `json:"ip_address"`
+	Port        uint64 `json:"port"`
+	Protocol    string `json:"protocol,omitempty"`
+	IsBootstrap bool   `json:"is_bootstrap"`
+	IsActive    bool   `json:"is_active"`
+}
+
+func (p PeerNode) SocketAddress() string {
+	return fmt.Sprintf("%s:%d", p.IpAddress, p.Port)
+}
+
+// Url returns the address of route on the peer. Peers that predate TLS
+// support do not advertise a protocol and are reached over plain http.
+func (p PeerNode) Url(route string) string {
+	protocol := p.Protocol
+	if protocol == "" {
+		protocol = ProtocolHttp
+	}
+	return fmt.Sprintf("%s://%s%s", protocol, p.SocketAddress(), route)
+}
+
+type PeerScore struct {
+	Score        int       `json:"score"`
+	Timeouts     uint      `json:"timeouts"`
+	Failures     uint      `json:"failures"`
+	InvalidData  uint      `json:"invalid_data"`
+	UsefulBlocks uint      `json:"useful_blocks"`
+	Bans         uint      `json:"bans"`
+	BannedUntil  time.Time `json:"banned_until"`
+	LastSeen     time.Time `json:"last_seen"`
+}
+
+func (s PeerScore) IsBanned(now time.Time) bool {
+	return now.Before(s.BannedUntil)
+}
+
+type PeerInfo struct {
+	PeerNode
+	Address string    `json:"address"`
+	Banned  bool      `json:"banned"`
+	Score   PeerScore `json:"score"`
+}
+
+type PeersResponse struct {
+	Peers []PeerInfo `json:"peers"`
+}
+
+// NodeInfo describes a node to its peers during the handshake.
+type NodeInfo struct {
+	NodeId          string        `json:"node_id"`
+	NodeVersion     string        `json:"node_version"`
+	ProtocolVersion int           `json:"protocol_version"`
+	ChainId         string        `json:"chain_id"`
+	GenesisHash     database.Hash `json:"genesis_hash"`
+	BestHeight      uint64        `json:"best_height"`
+	BestHash        database.Hash `json:"best_hash"`
+	Peer            PeerNode      `json:"peer"`
+}
+
+type HandshakeResponse struct {
+	Success bool     `json:"success"`
+	Message string   `json:"message"`
+	Info    NodeInfo `json:"info"`
+}
+
+// SyncProgress reports how far the node is through catching up with its
+// peers.
+type SyncProgress struct {
+	Syncing        bool   `json:"syncing"`
+	Peer           string `json:"peer,omitempty"`
+	StartingHeight uint64 `json:"starting_height"`
+	CurrentHeight  uint64 `json:"current_height"`
+	HighestHeight  uint64 `json:"highest_height"`
+}
+
+type StatusResponse struct {
+	Hash       database.Hash        `json:"block_hash"`
+	Number     uint64               `json:"block_number"`
+	KnownPeers map[string]PeerNode  `json:"known_peers"`
+	PeerScores map[string]PeerScore `json:"peer_scores"`
+	PendingTxs []database.Tx        `json:"pending_txs"`
+	Sync       SyncProgress         `json:"sync"`
+}
+
+type SyncResult struct {
+	Blocks []database.Block `json:"blocks"`
+	// Next is the after cursor of the following page, empty on the last one.
+	Next string `json:"next,omitempty"`
+}
+
+type HeaderEntry struct {
+	Hash   database.Hash        `json:"hash"`
+	Header database.BlockHeader `json:"header"`
+}
+
+type HeadersResult struct {
+	Headers []HeaderEntry `json:"headers"`
+	// Next is the after cursor of the following page, empty on the last one.
+	Next string `json:"next,omitempty"`
+}
+
+type BalancesResponse struct {
+	Hash     database.Hash             `json:"block_hash"`
+	Balances map[database.Account]uint `json:"balances"`
+}
+
+type BalanceResponse struct {
+	Hash    database.Hash    `json:"block_hash"`
+	Account database.Account `json:"account"`
+	Balance uint             `json:"balance"`
+}
+
+type TxAddRequest struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Value uint   `json:"value"`
+	Data  string `json:"data"`
+}
+
+type TxAddResponse struct {
+	Hash database.Hash `json:"tx_hash"`
+}
+
+type BlockResponse struct {
+	Hash     database.Hash   `json:"block_hash"`
+	Block    database.Block  `json:"block"`
+	TxHashes []database.Hash `json:"tx_hashes"`
+}
+
+type BlocksResponse struct {
+	Blocks []BlockResponse `json:"blocks"`
+}
+
+type TxResponse struct {
+	Hash     database.Hash       `json:"tx_hash"`
+	Location database.TxLocation `json:"location"`
+	Tx       database.Tx         `json:"tx"`
+}
+
+type AccountTxsResponse struct {
+	Account database.Account `json:"account"`
+	Txs     []TxResponse     `json:"txs"`
+	// Next is passed as after to get the following page, and is empty on
+	// the last one.
+	Next string `json:"next,omitempty"`
+}
+
+type PendingTxResponse struct {
+	Hash database.Hash `json:"tx_hash"`
+	Tx   database.Tx   `json:"tx"`
+}
+
+type PendingTxsResponse struct {
+	Txs []PendingTxResponse `json:"txs"`
+}
+
+type InventoryType string
+
+const (
+	InventoryBlock InventoryType = "block"
+	InventoryTx    InventoryType = "tx"
+)
+
+// InventoryMessage announces objects by hash. Receivers fetch the ones they
+// are missing from the announcing peer.
+type InventoryMessage struct {
+	Type   InventoryType   `json:"type"`
+	Hashes []database.Hash `json:"hashes"`
+	From   PeerNode        `json:"from"`
+}
+
+type BlockTemplate struct {
+	Header     database.BlockHeader `json:"header"`
+	Difficulty int                  `json:"difficulty"`
+	Target     string               `json:"target"`
+	Txs        []database.Tx        `json:"payload"`
+}
+
+type SubmitBlockResponse struct {
+	Hash     database.Hash `json:"block_hash"`
+	Accepted bool          `json:"accepted"`
+}
+
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
@@ -0,0 +1,383 @@
+// Package client talks to a yarbit node over its HTTP API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kparkins/yarbit/database"
+	"github.com/pkg/errors"
+)
+
+const (
+	DefaultTimeout = 10 * time.Second
+	DefaultRetries = 3
+	DefaultBackoff = 250 * time.Millisecond
+)
+
+// maxBackoff caps the delay between retries, including delays requested by
+// the node with Retry-After.
+const maxBackoff = 10 * time.Second
+
+// ErrInvalidResponse marks responses that could not be decoded.
+var ErrInvalidResponse = errors.New("invalid response")
+
+// Error is returned when the node answers with a status other than 200 OK.
+// Message holds the error reported in the node's ErrorResponse, if any.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("node returned %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("node returned %d: %s", e.StatusCode, e.Message)
+}
+
+// StatusCode returns the http status of err if it is an *Error, otherwise 0.
+func StatusCode(err error) int {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+	return 0
+}
+
+func IsNotFound(err error) bool {
+	return StatusCode(err) == http.StatusNotFound
+}
+
+type Options struct {
+	// HttpClient sends the requests. A client with DefaultTimeout is used
+	// if it is nil.
+	HttpClient *http.Client
+	// ApiKey is sent in the X-Api-Key header when set.
+	ApiKey string
+	// Retries is how many times a failed request is repeated. Reads are
+	// retried on network errors and on 429, 502, 503 and 504 responses;
+	// writes only on 429, which the node sends before handling the request.
+	Retries int
+	// Backoff is the delay before the first retry. It doubles with every
+	// further retry.
+	Backoff time.Duration
+}
+
+// DefaultOptions returns options that retry failed requests.
+func DefaultOptions() Options {
+	return Options{Retries: DefaultRetries, Backoff: DefaultBackoff}
+}
+
+type Client struct {
+	baseUrl string
+	http    *http.Client
+	apiKey  string
+	retries int
+	backoff time.Duration
+}
+
+// New returns a client for the node at address, either host:port or a url
+// with an http or https scheme.
+func New(address string, options Options) *Client {
+	if !strings.Contains(address, "://") {
+		address = fmt.Sprintf("%s://%s", ProtocolHttp, address)
+	}
+	httpClient := options.HttpClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: DefaultTimeout}
+	}
+	backoff := options.Backoff
+	if backoff <= 0 {
+		backoff = DefaultBackoff
+	}
+	return &Client{
+		baseUrl: strings.TrimRight(address, "/"),
+		http:    httpClient,
+		apiKey:  options.ApiKey,
+		retries: options.Retries,
+		backoff: backoff,
+	}
+}
+
+// ForPeer returns a client for peer using the protocol it advertises.
+func ForPeer(peer PeerNode, options Options) *Client {
+	return New(peer.Url(""), options)
+}
+
+// Url returns the address of route on the node.
+func (c *Client) Url(route string) string {
+	return c.baseUrl + route
+}
+
+func (c *Client) Status(ctx context.Context) (StatusResponse, error) {
+	var result StatusResponse
+	err := c.get(ctx, ApiRouteStatus, nil, &result)
+	return result, err
+}
+
+func (c *Client) NodeInfo(ctx context.Context) (NodeInfo, error) {
+	var result NodeInfo
+	err := c.get(ctx, ApiRouteNodeInfo, nil, &result)
+	return result, err
+}
+
+func (c *Client) Peers(ctx context.Context) (PeersResponse, error) {
+	var result PeersResponse
+	err := c.get(ctx, ApiRouteListPeers, nil, &result)
+	return result, err
+}
+
+// AddPeer performs the handshake, introducing the node described by info.
+func (c *Client) AddPeer(ctx context.Context, info NodeInfo) (HandshakeResponse, error) {
+	var result HandshakeResponse
+	err := c.post(ctx, ApiRouteAddPeer, info, &result)
+	return result, err
+}
+
+func (c *Client) Balances(ctx context.Context) (BalancesResponse, error) {
+	var result BalancesResponse
+	err := c.get(ctx, ApiRouteListBalances, nil, &result)
+	return result, err
+}
+
+// BalancesAt returns the balances after the block at height.
+func (c *Client) BalancesAt(ctx context.Context, height uint64) (BalancesResponse, error) {
+	var result BalancesResponse
+	query := url.Values{ApiQueryParamHeight: {strconv.FormatUint(height, 10)}}
+	err := c.get(ctx, ApiRouteListBalances, query, &result)
+	return result, err
+}
+
+func (c *Client) Balance(ctx context.Context, account database.Account) (BalanceResponse, error) {
+	var result BalanceResponse
+	err := c.get(ctx, fmt.Sprintf("/balances/%s", url.PathEscape(string(account))), nil, &result)
+	return result, err
+}
+
+func (c *Client) AddTx(ctx context.Context, tx TxAddRequest) (database.Hash, error) {
+	var result TxAddResponse
+	err := c.post(ctx, ApiRouteAddTx, tx, &result)
+	return result.Hash, err
+}
+
+// Sync returns up to limit blocks following the block with hash after, or
+// from genesis if after is empty.
+func (c *Client) Sync(ctx context.Context, after string, limit uint64) (SyncResult, error) {
+	var result SyncResult
+	err := c.get(ctx, ApiRouteSync, pageQuery(after, limit), &result)
+	return result, err
+}
+
+// Headers returns up to limit headers following the block with hash after,
+// or from genesis if after is empty.
+func (c *Client) Headers(ctx context.Context, after string, limit uint64) (HeadersResult, error) {
+	var result HeadersResult
+	err := c.get(ctx, ApiRouteHeaders, pageQuery(after, limit), &result)
+	return result, err
+}
+
+// Blocks returns up to limit of the most recent blocks, newest first. The
+// node's default is used when limit is 0.
+func (c *Client) Blocks(ctx context.Context, limit uint64) (BlocksResponse, error) {
+	var result BlocksResponse
+	var query url.Values
+	if limit > 0 {
+		query = url.Values{ApiQueryParamLimit: {strconv.FormatUint(limit, 10)}}
+	}
+	err := c.get(ctx, ApiRouteListBlocks, query, &result)
+	return result, err
+}
+
+// Block returns the block with id, either a block hash or a height.
+func (c *Client) Block(ctx context.Context, id string) (BlockResponse, error) {
+	var result BlockResponse
+	err := c.get(ctx, fmt.Sprintf("%s/%s", ApiRouteListBlocks, url.PathEscape(id)), nil, &result)
+	return result, err
+}
+
+func (c *Client) Tx(ctx context.Context, hash database.Hash) (TxResponse, error) {
+	var result TxResponse
+	err := c.get(ctx, fmt.Sprintf("/tx/%s", hash), nil, &result)
+	return result, err
+}
+
+// PendingTxs returns the transactions in the node's mempool.
+func (c *Client) PendingTxs(ctx context.Context) (PendingTxsResponse, error) {
+	var result PendingTxsResponse
+	err := c.get(ctx, ApiRoutePendingTxs, nil, &result)
+	return result, err
+}
+
+func (c *Client) PendingTx(ctx context.Context, hash database.Hash) (PendingTxResponse, error) {
+	var result PendingTxResponse
+	err := c.get(ctx, fmt.Sprintf("%s/%s", ApiRoutePendingTxs, hash), nil, &result)
+	return result, err
+}
+
+// AccountTxs returns a page of the txs of account, oldest first, starting
+// after the tx with hash after or at the first one when after is empty.
+func (c *Client) AccountTxs(ctx context.Context, account database.Account, after string, limit uint64) (AccountTxsResponse, error) {
+	var result AccountTxsResponse
+	query := url.Values{}
+	if after != "" {
+		query.Set(ApiQueryParamAfter, after)
+	}
+	if limit > 0 {
+		query.Set(ApiQueryParamLimit, strconv.FormatUint(limit, 10))
+	}
+	err := c.get(ctx, fmt.Sprintf("/accounts/%s/txs", url.PathEscape(string(account))), query, &result)
+	return result, err
+}
+
+// SendInventory announces objects to the node.
+func (c *Client) SendInventory(ctx context.Context, message InventoryMessage) error {
+	return c.post(ctx, ApiRouteInventory, message, nil)
+}
+
+// MiningTemplate returns a block template crediting account, or the node's
+// miner account if account is empty.
+func (c *Client) MiningTemplate(ctx context.Context, account string) (BlockTemplate, error) {
+	var result BlockTemplate
+	var query url.Values
+	if account != "" {
+		query = url.Values{ApiQueryParamAccount: {account}}
+	}
+	err := c.get(ctx, ApiRouteMiningWork, query, &result)
+	return result, err
+}
+
+func (c *Client) SubmitBlock(ctx context.Context, block *database.Block) (SubmitBlockResponse, error) {
+	var result SubmitBlockResponse
+	err := c.post(ctx, ApiRouteMiningSubmit, block, &result)
+	return result, err
+}
+
+func pageQuery(after string, limit uint64) url.Values {
+	return url.Values{
+		ApiQueryParamAfter: {after},
+		ApiQueryParamLimit: {strconv.FormatUint(limit, 10)},
+	}
+}
+
+func (c *Client) get(ctx context.Context, route string, query url.Values, result interface{}) error {
+	target := c.Url(route)
+	if len(query) > 0 {
+		target = fmt.Sprintf("%s?%s", target, query.Encode())
+	}
+	return c.do(ctx, "GET", target, nil, result)
+}
+
+func (c *Client) post(ctx context.Context, route string, body interface{}, result interface{}) error {
+	content, err := json.Marshal(body)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling request body")
+	}
+	return c.do(ctx, "POST", c.Url(route), content, result)
+}
+
+// do sends the request, retrying failures the node is expected to recover
+// from, and decodes a successful response into result.
+func (c *Client) do(ctx context.Context, method, target string, body []byte, result interface{}) error {
+	backoff := c.backoff
+	for attempt := 0; ; attempt++ {
+		response, err := c.send(ctx, method, target, body)
+		if err == nil && response.StatusCode == http.StatusOK {
+			return decodeResponse(response, result)
+		}
+		var delay time.Duration
+		if err == nil {
+			delay = retryAfter(response)
+			err = decodeError(response)
+		}
+		if attempt >= c.retries || ctx.Err() != nil || !retryable(method, err) {
+			return err
+		}
+		if delay < backoff {
+			delay = backoff
+		}
+		if delay > maxBackoff {
+			delay = maxBackoff
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		backoff *= 2
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, target string, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "while creating request")
+	}
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		request.Header.Set(HeaderApiKey, c.apiKey)
+	}
+	response, err := c.http.Do(request)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error calling %s", request.URL.Host)
+	}
+	return response, nil
+}
+
+func retryable(method string, err error) bool {
+	switch StatusCode(err) {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return method == "GET"
+	case 0:
+		return method == "GET" && !errors.Is(err, ErrInvalidResponse)
+	}
+	return false
+}
+
+func retryAfter(response *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(response.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func decodeResponse(response *http.Response, result interface{}) error {
+	defer response.Body.Close()
+	content, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return errors.Wrap(err, "invalid response body")
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(content, result); err != nil {
+		return errors.Wrapf(ErrInvalidResponse, "failed to deserialize response body: %v", err)
+	}
+	return nil
+}
+
+func decodeError(response *http.Response) error {
+	defer response.Body.Close()
+	var errorResponse ErrorResponse
+	content, _ := ioutil.ReadAll(response.Body)
+	_ = json.Unmarshal(content, &errorResponse)
+	return &Error{StatusCode: response.StatusCode, Message: errorResponse.Error}
+}
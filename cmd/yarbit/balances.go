@@ -1,12 +1,16 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"github.com/kparkins/yarbit/client"
 	"github.com/kparkins/yarbit/database"
 	"github.com/spf13/cobra"
 	"os"
 )
 
+const flagHeight = "height"
+
 func balancesCommand() *cobra.Command {
 	command := &cobra.Command{
 		Use:   "balances",
@@ -22,21 +26,59 @@ func balancesCommand() *cobra.Command {
 func balancesListCommand() *cobra.Command {
 	command := &cobra.Command{
 		Use:   "list",
-		Short: "List all balances.",
+		Short: "List all balances, read from --datadir or from a running --node.",
 		Run: func(cmd *cobra.Command, args []string) {
 			dataDir, _ := cmd.Flags().GetString(flagDataDir)
-			state := database.NewStateFromDisk(dataDir)
-			if err := state.Load(); err != nil {
+			address, _ := cmd.Flags().GetString(flagNode)
+			var result client.BalancesResponse
+			var err error
+			switch {
+			case address != "":
+				result, err = fetchBalances(cmd)
+			case dataDir != "":
+				result, err = readBalances(cmd, dataDir)
+			default:
+				err = fmt.Errorf("one of --%s or --%s is required", flagDataDir, flagNode)
+			}
+			if err != nil {
 				fmt.Fprintln(os.Stderr, err)
 				os.Exit(1)
 			}
-			fmt.Printf("Account balances at %s\n", state.LatestBlockHash())
-			fmt.Printf("------------------\n\n")
-			for account, balance := range state.Balances() {
-				fmt.Println(fmt.Sprintf("%10s: %10d", account, balance))
-			}
+			printResult(cmd, result, func() {
+				fmt.Printf("Account balances at %s\n", result.Hash)
+				fmt.Printf("------------------\n\n")
+				for account, balance := range result.Balances {
+					fmt.Println(fmt.Sprintf("%10s: %10d", account, balance))
+				}
+			})
 		},
 	}
-	addDefaultRequiredFlags(command)
+	command.Flags().String(flagDataDir, "", "Path to the database directory.")
+	addRemoteFlags(command, "")
+	command.Flags().Uint64(flagHeight, 0, "Block height to list balances at. Defaults to the latest block.")
 	return command
 }
+
+// fetchBalances asks the node for its balances. Unlike reading the data dir
+// this works against a node on another host and does not race its writes.
+func fetchBalances(cmd *cobra.Command) (client.BalancesResponse, error) {
+	ctx := context.Background()
+	if cmd.Flags().Changed(flagHeight) {
+		height, _ := cmd.Flags().GetUint64(flagHeight)
+		return remoteClient(cmd).BalancesAt(ctx, height)
+	}
+	return remoteClient(cmd).Balances(ctx)
+}
+
+func readBalances(cmd *cobra.Command, dataDir string) (client.BalancesResponse, error) {
+	state := database.NewStateFromDisk(dataDir)
+	if err := state.Load(); err != nil {
+		return client.BalancesResponse{}, err
+	}
+	if cmd.Flags().Changed(flagHeight) {
+		height, _ := cmd.Flags().GetUint64(flagHeight)
+		hash, balances, err := state.BalancesAt(height)
+		return client.BalancesResponse{Hash: hash, Balances: balances}, err
+	}
+	return client.BalancesResponse{Hash: state.LatestBlockHash(), Balances: state.Balances()}, nil
+}
@@ -0,0 +1,458 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kparkins/yarbit/database"
+	"github.com/kparkins/yarbit/logging"
+	"github.com/kparkins/yarbit/node"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const flagConfig = "config"
+const flagMiner = "miner"
+const flagMining = "mining"
+const flagMiningWorkers = "mining-workers"
+const flagSyncInterval = "sync-interval"
+const flagSignerKey = "signer-key"
+const flagDev = "dev"
+const flagTlsCert = "tls-cert"
+const flagTlsKey = "tls-key"
+const flagTlsCa = "tls-ca"
+const flagMutualTls = "mtls"
+const flagGrpcPort = "grpc-port"
+const flagApiKeys = "api-keys"
+const flagPeerToken = "peer-token"
+const flagMaxBodyBytes = "max-body-bytes"
+const flagRateLimitTx = "rate-limit-tx"
+const flagRateLimitSync = "rate-limit-sync"
+const flagRateLimitStatus = "rate-limit-status"
+const flagMempoolMaxTxs = "mempool-max-txs"
+const flagMempoolMaxBytes = "mempool-max-bytes"
+const flagMempoolMaxPerSender = "mempool-max-per-sender"
+const flagMempoolTtl = "mempool-ttl"
+
+const envPrefix = "YARBIT_"
+const envConfig = envPrefix + "CONFIG"
+
+const configPrecedence = `Settings are resolved in the following order, later sources overriding
+earlier ones:
+
+  1. built-in defaults
+  2. the JSON config file given by --config or YARBIT_CONFIG
+  3. YARBIT_* environment variables (e.g. YARBIT_PORT, YARBIT_BOOTSTRAP)
+  4. command line flags`
+
+// runConfig is the user facing node configuration as read from a config file,
+// the environment and flags.
+type runConfig struct {
+	DataDir             string            `json:"datadir"`
+	Ip                  string            `json:"ip"`
+	Port                uint64            `json:"port"`
+	GrpcPort            uint64            `json:"grpc_port"`
+	Bootstrap           []string          `json:"bootstrap"`
+	MinerAccount        string            `json:"miner"`
+	Mining              bool              `json:"mining"`
+	MiningWorkers       int               `json:"mining_workers"`
+	SignerKey           string            `json:"signer_key"`
+	Dev                 bool              `json:"dev"`
+	SyncInterval        string            `json:"sync_interval"`
+	TlsCert             string            `json:"tls_cert"`
+	TlsKey              string            `json:"tls_key"`
+	TlsCa               string            `json:"tls_ca"`
+	MutualTls           bool              `json:"mtls"`
+	ApiKeys             string            `json:"api_keys"`
+	PeerToken           string            `json:"peer_token"`
+	MaxBodyBytes        int64             `json:"max_body_bytes"`
+	RateLimits          map[string]string `json:"rate_limits"`
+	MempoolMaxTxs       int               `json:"mempool_max_txs"`
+	MempoolMaxBytes     int               `json:"mempool_max_bytes"`
+	MempoolMaxPerSender int               `json:"mempool_max_per_sender"`
+	MempoolTtl          string            `json:"mempool_ttl"`
+	LogFormat           string            `json:"log_format"`
+	LogLevel            string            `json:"log_level"`
+	LogComponents       string            `json:"log_components"`
+}
+
+type configSetting struct {
+	flag string
+	set  func(c *runConfig, value string) error
+}
+
+var configSettings = []configSetting{
+	{flagDataDir, func(c *runConfig, v string) error { c.DataDir = v; return nil }},
+	{flagIp, func(c *runConfig, v string) error { c.Ip = v; return nil }},
+	{flagPort, func(c *runConfig, v string) (err error) { c.Port, err = strconv.ParseUint(v, 10, 64); return }},
+	{flagGrpcPort, func(c *runConfig, v string) (err error) { c.GrpcPort, err = strconv.ParseUint(v, 10, 64); return }},
+	{flagBootstrap, func(c *runConfig, v string) error { c.Bootstrap = splitList(v); return nil }},
+	{flagMiner, func(c *runConfig, v string) error { c.MinerAccount = v; return nil }},
+	{flagMining, func(c *runConfig, v string) (err error) { c.Mining, err = strconv.ParseBool(v); return }},
+	{flagMiningWorkers, func(c *runConfig, v string) (err error) { c.MiningWorkers, err = strconv.Atoi(v); return }},
+	{flagSignerKey, func(c *runConfig, v string) error { c.SignerKey = v; return nil }},
+	{flagDev, func(c *runConfig, v string) (err error) { c.Dev, err = strconv.ParseBool(v); return }},
+	{flagSyncInterval, func(c *runConfig, v string) error { c.SyncInterval = v; return nil }},
+	{flagTlsCert, func(c *runConfig, v string) error { c.TlsCert = v; return nil }},
+	{flagTlsKey, func(c *runConfig, v string) error { c.TlsKey = v; return nil }},
+	{flagTlsCa, func(c *runConfig, v string) error { c.TlsCa = v; return nil }},
+	{flagMutualTls, func(c *runConfig, v string) (err error) { c.MutualTls, err = strconv.ParseBool(v); return }},
+	{flagApiKeys, func(c *runConfig, v string) error { c.ApiKeys = v; return nil }},
+	{flagPeerToken, func(c *runConfig, v string) error { c.PeerToken = v; return nil }},
+	{flagMaxBodyBytes, func(c *runConfig, v string) (err error) { c.MaxBodyBytes, err = strconv.ParseInt(v, 10, 64); return }},
+	{flagRateLimitTx, rateLimitSetting(node.RateClassTx)},
+	{flagRateLimitSync, rateLimitSetting(node.RateClassSync)},
+	{flagRateLimitStatus, rateLimitSetting(node.RateClassStatus)},
+	{flagMempoolMaxTxs, func(c *runConfig, v string) (err error) { c.MempoolMaxTxs, err = strconv.Atoi(v); return }},
+	{flagMempoolMaxBytes, func(c *runConfig, v string) (err error) { c.MempoolMaxBytes, err = strconv.Atoi(v); return }},
+	{flagMempoolMaxPerSender, func(c *runConfig, v string) (err error) { c.MempoolMaxPerSender, err = strconv.Atoi(v); return }},
+	{flagMempoolTtl, func(c *runConfig, v string) error { c.MempoolTtl = v; return nil }},
+	{flagLogFormat, func(c *runConfig, v string) error { c.LogFormat = v; return nil }},
+	{flagLogLevel, func(c *runConfig, v string) error { c.LogLevel = v; return nil }},
+	{flagLogComponents, func(c *runConfig, v string) error { c.LogComponents = v; return nil }},
+}
+
+func defaultRunConfig() runConfig {
+	limits := node.DefaultRateLimits()
+	return runConfig{
+		Ip:                  "127.0.0.1",
+		Port:                80,
+		Bootstrap:           []string{},
+		MinerAccount:        "miner",
+		Mining:              true,
+		MiningWorkers:       runtime.NumCPU(),
+		SyncInterval:        node.DefaultSyncInterval.String(),
+		LogFormat:           string(logging.FormatText),
+		LogLevel:            "info",
+		MaxBodyBytes:        node.DefaultMaxBodyBytes,
+		MempoolMaxTxs:       node.DefaultMempoolMaxTxs,
+		MempoolMaxBytes:     node.DefaultMempoolMaxBytes,
+		MempoolMaxPerSender: node.DefaultMempoolMaxPerSender,
+		MempoolTtl:          node.DefaultMempoolTtl.String(),
+		RateLimits: map[string]string{
+			string(node.RateClassTx):     formatRateLimit(limits[node.RateClassTx]),
+			string(node.RateClassSync):   formatRateLimit(limits[node.RateClassSync]),
+			string(node.RateClassStatus): formatRateLimit(limits[node.RateClassStatus]),
+		},
+	}
+}
+
+func configCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the node configuration (print...)",
+		Run: func(cmd *cobra.Command, args []string) {
+
+		},
+	}
+	command.AddCommand(configPrintCommand())
+	return command
+}
+
+func configPrintCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "print",
+		Short: "Print the effective node configuration.",
+		Long:  "Print the effective node configuration.\n\n" + configPrecedence,
+		Run: func(cmd *cobra.Command, args []string) {
+			config, err := loadRunConfig(cmd)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			// The peer token is a credential, only show whether it is set.
+			if config.PeerToken != "" {
+				config.PeerToken = "[redacted]"
+			}
+			content, _ := json.MarshalIndent(config, "", "  ")
+			fmt.Println(string(content))
+		},
+	}
+	addRunConfigFlags(command)
+	return command
+}
+
+func addRunConfigFlags(command *cobra.Command) {
+	defaults := defaultRunConfig()
+	command.Flags().String(flagConfig, "", "Path to a JSON config file.")
+	command.Flags().String(flagDataDir, defaults.DataDir, "Path to the database directory.")
+	command.Flags().String(flagIp, defaults.Ip, "the ip of the node")
+	command.Flags().Uint64(flagPort, defaults.Port, "the port of the node")
+	command.Flags().Uint64(flagGrpcPort, defaults.GrpcPort, "the port of the gRPC API, disabled when 0")
+	command.Flags().String(flagBootstrap, "", "Comma separated ip:port list of bootstrap nodes, optionally prefixed with http:// or https://. If empty, defaults to being the bootstrap node.")
+	command.Flags().String(flagMiner, defaults.MinerAccount, "account credited with mining rewards")
+	command.Flags().Bool(flagMining, defaults.Mining, "whether the node mines new blocks")
+	command.Flags().Int(flagMiningWorkers, defaults.MiningWorkers, "number of concurrent mining workers")
+	command.Flags().String(flagSignerKey, defaults.SignerKey, "path to the proof of authority signing key of the miner account")
+	command.Flags().Bool(flagDev, defaults.Dev, "run a development chain with prefunded accounts that seals blocks instantly")
+	command.Flags().String(flagSyncInterval, defaults.SyncInterval, "how often to sync with peers")
+	command.Flags().String(flagTlsCert, defaults.TlsCert, "path to a PEM certificate; serves the api and peer traffic over https")
+	command.Flags().String(flagTlsKey, defaults.TlsKey, "path to the PEM private key of --tls-cert")
+	command.Flags().String(flagTlsCa, defaults.TlsCa, "path to a PEM CA bundle trusted for peer certificates")
+	command.Flags().Bool(flagMutualTls, defaults.MutualTls, "require clients and peers to present a certificate signed by --tls-ca")
+	command.Flags().String(flagApiKeys, defaults.ApiKeys, "path to a JSON list of api keys and their roles; the api is open when unset")
+	command.Flags().String(flagPeerToken, defaults.PeerToken, "api key with the peer role sent when calling peers")
+	command.Flags().Int64(flagMaxBodyBytes, defaults.MaxBodyBytes, "maximum size of a request body in bytes")
+	command.Flags().String(flagRateLimitTx, defaults.RateLimits[string(node.RateClassTx)], "per-ip limit on tx submission as requests per second:burst, 0 to disable")
+	command.Flags().String(flagRateLimitSync, defaults.RateLimits[string(node.RateClassSync)], "per-ip limit on sync and gossip requests as requests per second:burst, 0 to disable")
+	command.Flags().String(flagRateLimitStatus, defaults.RateLimits[string(node.RateClassStatus)], "per-ip limit on status and chain queries as requests per second:burst, 0 to disable")
+	command.Flags().Int(flagMempoolMaxTxs, defaults.MempoolMaxTxs, "maximum number of pending txs")
+	command.Flags().Int(flagMempoolMaxBytes, defaults.MempoolMaxBytes, "maximum total size of pending txs in bytes")
+	command.Flags().Int(flagMempoolMaxPerSender, defaults.MempoolMaxPerSender, "maximum number of pending txs from one account")
+	command.Flags().String(flagMempoolTtl, defaults.MempoolTtl, "how long a tx may wait to be mined before it is dropped")
+	command.Flags().String(flagLogFormat, defaults.LogFormat, "log output format (text or json)")
+	command.Flags().String(flagLogLevel, defaults.LogLevel, "minimum log level (debug, info, warn, error)")
+	command.Flags().String(flagLogComponents, defaults.LogComponents, "per-component log levels, e.g. sync=debug,miner=warn")
+}
+
+func loadRunConfig(cmd *cobra.Command) (runConfig, error) {
+	config := defaultRunConfig()
+	path, _ := cmd.Flags().GetString(flagConfig)
+	if !cmd.Flags().Changed(flagConfig) {
+		path = os.Getenv(envConfig)
+	}
+	if path != "" {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return config, errors.Wrap(err, "failed to read config file")
+		}
+		if err := json.Unmarshal(content, &config); err != nil {
+			return config, errors.Wrap(err, "failed to parse config file")
+		}
+	}
+	for _, setting := range configSettings {
+		if value, ok := os.LookupEnv(envName(setting.flag)); ok {
+			if err := setting.set(&config, value); err != nil {
+				return config, errors.Wrap(err, fmt.Sprintf("invalid %s", envName(setting.flag)))
+			}
+		}
+	}
+	for _, setting := range configSettings {
+		flag := cmd.Flags().Lookup(setting.flag)
+		if flag == nil || !flag.Changed {
+			continue
+		}
+		if err := setting.set(&config, flag.Value.String()); err != nil {
+			return config, errors.Wrap(err, fmt.Sprintf("invalid --%s", setting.flag))
+		}
+	}
+	return config, nil
+}
+
+func (c runConfig) nodeConfig() (node.Config, error) {
+	if c.DataDir == "" {
+		return node.Config{}, fmt.Errorf("a data directory is required")
+	}
+	syncInterval, err := time.ParseDuration(c.SyncInterval)
+	if err != nil {
+		return node.Config{}, errors.Wrap(err, "invalid sync interval")
+	}
+	mempoolTtl, err := time.ParseDuration(c.MempoolTtl)
+	if err != nil {
+		return node.Config{}, errors.Wrap(err, "invalid mempool ttl")
+	}
+	logger, err := c.logger()
+	if err != nil {
+		return node.Config{}, err
+	}
+	apiKeys, err := loadApiKeys(c.ApiKeys)
+	if err != nil {
+		return node.Config{}, err
+	}
+	// Without any rate limits the node falls back to its defaults.
+	var rateLimits node.RateLimits
+	for class, value := range c.RateLimits {
+		if rateLimits == nil {
+			rateLimits = make(node.RateLimits)
+		}
+		limit, err := parseRateLimit(value)
+		if err != nil {
+			return node.Config{}, errors.Wrap(err, fmt.Sprintf("invalid %s rate limit", class))
+		}
+		rateLimits[node.RateClass(class)] = limit
+	}
+	protocol := node.ProtocolHttp
+	if c.TlsCert != "" {
+		protocol = node.ProtocolHttps
+	}
+	bootstraps := make([]node.PeerNode, 0, len(c.Bootstrap))
+	for _, address := range c.Bootstrap {
+		peerProtocol, address := splitProtocol(address, protocol)
+		ip, port, err := parseSocketAddress(address)
+		if err != nil {
+			return node.Config{}, err
+		}
+		bootstraps = append(bootstraps, node.PeerNode{
+			IpAddress:   ip,
+			Port:        port,
+			Protocol:    peerProtocol,
+			IsBootstrap: true,
+			IsActive:    true,
+		})
+	}
+	return node.Config{
+		Version:      fmt.Sprintf("%s.%s.%s", MajorVersion, MinorVersion, FixVersion),
+		DataDir:      c.DataDir,
+		IpAddress:    c.Ip,
+		Port:         c.Port,
+		GrpcPort:     c.GrpcPort,
+		Protocol:     protocol,
+		TlsCertFile:  c.TlsCert,
+		TlsKeyFile:   c.TlsKey,
+		TlsCaFile:    c.TlsCa,
+		MutualTls:    c.MutualTls,
+		ApiKeys:      apiKeys,
+		PeerToken:    c.PeerToken,
+		MaxBodyBytes: c.MaxBodyBytes,
+		RateLimits:   rateLimits,
+		Mempool: node.MempoolLimits{
+			MaxTxs:       c.MempoolMaxTxs,
+			MaxBytes:     c.MempoolMaxBytes,
+			MaxPerSender: c.MempoolMaxPerSender,
+			Ttl:          mempoolTtl,
+		},
+		Bootstraps:    bootstraps,
+		MinerAccount:  database.NewAccount(c.MinerAccount),
+		MiningEnabled: c.Mining,
+		MiningWorkers: c.MiningWorkers,
+		SignerKeyFile: c.SignerKey,
+		Dev:           c.Dev,
+		SyncInterval:  syncInterval,
+		Logger:        logger,
+	}, nil
+}
+
+func (c runConfig) logger() (logging.Logger, error) {
+	format, err := logging.ParseFormat(c.LogFormat)
+	if err != nil {
+		return nil, err
+	}
+	level, err := logging.ParseLevel(c.LogLevel)
+	if err != nil {
+		return nil, err
+	}
+	components, err := logging.ParseComponentLevels(c.LogComponents)
+	if err != nil {
+		return nil, err
+	}
+	return logging.New(logging.Options{
+		Output:          os.Stderr,
+		Format:          format,
+		Level:           level,
+		ComponentLevels: components,
+	}), nil
+}
+
+func rateLimitSetting(class node.RateClass) func(c *runConfig, v string) error {
+	return func(c *runConfig, v string) error {
+		if _, err := parseRateLimit(v); err != nil {
+			return err
+		}
+		// A config file with "rate_limits": null leaves no map to set into.
+		if c.RateLimits == nil {
+			c.RateLimits = make(map[string]string)
+		}
+		c.RateLimits[string(class)] = v
+		return nil
+	}
+}
+
+// parseRateLimit reads a rate limit written as rate:burst, or just the rate
+// with a burst of twice the rate.
+func parseRateLimit(value string) (node.RateLimit, error) {
+	parts := strings.SplitN(value, ":", 2)
+	rate, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return node.RateLimit{}, errors.Wrap(err, fmt.Sprintf("invalid rate in '%s'", value))
+	}
+	burst := int(math.Ceil(rate * 2))
+	if len(parts) == 2 {
+		if burst, err = strconv.Atoi(parts[1]); err != nil {
+			return node.RateLimit{}, errors.Wrap(err, fmt.Sprintf("invalid burst in '%s'", value))
+		}
+	}
+	return node.RateLimit{Rate: rate, Burst: burst}, nil
+}
+
+func formatRateLimit(limit node.RateLimit) string {
+	return fmt.Sprintf("%s:%d", strconv.FormatFloat(limit.Rate, 'f', -1, 64), limit.Burst)
+}
+
+// loadApiKeys reads the api keys file, a JSON list of objects with a name,
+// key and role.
+func loadApiKeys(path string) ([]node.ApiKey, error) {
+	if path == "" {
+		return nil, nil
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read api keys")
+	}
+	var keys []node.ApiKey
+	if err := json.Unmarshal(content, &keys); err != nil {
+		return nil, errors.Wrap(err, "failed to parse api keys")
+	}
+	for _, key := range keys {
+		if key.Key == "" {
+			return nil, fmt.Errorf("api key '%s' is empty", key.Name)
+		}
+		if _, err := node.ParseRole(string(key.Role)); err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("api key '%s'", key.Name))
+		}
+	}
+	return keys, nil
+}
+
+// loggerFromFlags builds a logger from just the logging flags, for commands
+// that do not take the full node configuration.
+func loggerFromFlags(cmd *cobra.Command) (logging.Logger, error) {
+	config := defaultRunConfig()
+	config.LogFormat, _ = cmd.Flags().GetString(flagLogFormat)
+	config.LogLevel, _ = cmd.Flags().GetString(flagLogLevel)
+	config.LogComponents, _ = cmd.Flags().GetString(flagLogComponents)
+	return config.logger()
+}
+
+func envName(flag string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+}
+
+func splitList(value string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// splitProtocol strips an http:// or https:// prefix from address, returning
+// fallback as the protocol when there is none.
+func splitProtocol(address string, fallback string) (string, string) {
+	for _, protocol := range []string{node.ProtocolHttp, node.ProtocolHttps} {
+		if strings.HasPrefix(address, protocol+"://") {
+			return protocol, strings.TrimPrefix(address, protocol+"://")
+		}
+	}
+	return fallback, address
+}
+
+func parseSocketAddress(address string) (string, uint64, error) {
+	parts := strings.Split(address, ":")
+	if len(parts) != 2 {
+		return "", 0, fmt.Errorf("invalid address '%s', expected ip:port", address)
+	}
+	port, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return "", 0, errors.Wrap(err, fmt.Sprintf("invalid port in '%s'", address))
+	}
+	return parts[0], port, nil
+}
@@ -22,6 +22,13 @@ func main() {
 	command.AddCommand(balancesCommand())
 	command.AddCommand(runCommand())
 	command.AddCommand(migrateCommand())
+	command.AddCommand(configCommand())
+	command.AddCommand(minerCommand())
+	command.AddCommand(signerCommand())
+	command.AddCommand(statusCommand())
+	command.AddCommand(peersCommand())
+	command.AddCommand(mempoolCommand())
+	command.AddCommand(txCommand())
 
 	err := command.Execute()
 	if err != nil {
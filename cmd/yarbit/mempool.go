@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/spf13/cobra"
+)
+
+func mempoolCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "mempool",
+		Short: "Inspect the pending txs of a running node (list...)",
+		Run: func(cmd *cobra.Command, args []string) {
+
+		},
+	}
+	command.AddCommand(mempoolListCommand())
+	return command
+}
+
+func mempoolListCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "list",
+		Short: "List the txs waiting to be mined.",
+		Run: func(cmd *cobra.Command, args []string) {
+			result, err := remoteClient(cmd).PendingTxs(context.Background())
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			sort.Slice(result.Txs, func(i, j int) bool {
+				return result.Txs[i].Tx.Time < result.Txs[j].Tx.Time
+			})
+			printResult(cmd, result, func() {
+				table := newTable()
+				fmt.Fprintln(table, "HASH\tFROM\tTO\tVALUE\tDATA")
+				for _, pending := range result.Txs {
+					tx := pending.Tx
+					fmt.Fprintf(table, "%s\t%s\t%s\t%d\t%s\n", pending.Hash, tx.From, tx.To, tx.Value, tx.Data)
+				}
+				table.Flush()
+			})
+		},
+	}
+	addRemoteFlags(command, defaultNodeAddress())
+	return command
+}
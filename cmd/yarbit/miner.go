@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+	"time"
+
+	"github.com/kparkins/yarbit/client"
+	"github.com/kparkins/yarbit/consensus"
+	"github.com/kparkins/yarbit/database"
+	"github.com/kparkins/yarbit/logging"
+	"github.com/spf13/cobra"
+)
+
+const flagNode = "node"
+const flagAccount = "account"
+const flagWorkers = "workers"
+const flagPollInterval = "poll-interval"
+const flagApiKey = "api-key"
+
+func minerCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "miner",
+		Short: "Mine blocks for a remote node using its block templates.",
+		Run: func(cmd *cobra.Command, args []string) {
+			address, _ := cmd.Flags().GetString(flagNode)
+			account, _ := cmd.Flags().GetString(flagAccount)
+			workers, _ := cmd.Flags().GetInt(flagWorkers)
+			poll, _ := cmd.Flags().GetDuration(flagPollInterval)
+			logger, err := loggerFromFlags(cmd)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			quit := make(chan os.Signal, 1)
+			signal.Notify(quit, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+			go func() {
+				<-quit
+				cancel()
+			}()
+			miner := &remoteMiner{
+				client:  remoteClient(cmd),
+				address: address,
+				account: account,
+				workers: workers,
+				poll:    poll,
+				logger:  logger.Component("miner"),
+			}
+			miner.run(ctx)
+		},
+	}
+	command.Flags().String(flagNode, "", "host:port or url of the node to mine for")
+	command.MarkFlagRequired(flagNode)
+	command.Flags().String(flagAccount, "", "account credited with mining rewards, defaults to the node's miner account")
+	command.Flags().Int(flagWorkers, runtime.NumCPU(), "number of concurrent mining workers")
+	command.Flags().Duration(flagPollInterval, 5*time.Second, "how often to check the node for a new block template")
+	command.Flags().String(flagApiKey, "", "api key with the admin role, if the node requires one")
+	addRemoteTlsFlags(command)
+	command.Flags().String(flagLogFormat, "text", "log output format (text or json)")
+	command.Flags().String(flagLogLevel, "info", "minimum log level (debug, info, warn, error)")
+	command.Flags().String(flagLogComponents, "", "per-component log levels, e.g. miner=debug")
+	return command
+}
+
+type remoteMiner struct {
+	client  *client.Client
+	address string
+	account string
+	workers int
+	poll    time.Duration
+	logger  logging.Logger
+}
+
+func (m *remoteMiner) run(ctx context.Context) {
+	for ctx.Err() == nil {
+		template, err := m.client.MiningTemplate(ctx, m.account)
+		if err != nil {
+			m.logger.Warn("error fetching block template", "node", m.address, "error", err)
+			sleep(ctx, m.poll)
+			continue
+		}
+		if len(template.Txs) == 0 {
+			m.logger.Debug("no pending txs to mine", "node", m.address)
+			sleep(ctx, m.poll)
+			continue
+		}
+		block := &database.Block{Header: template.Header, Txs: template.Txs}
+		mineCtx, cancelMining := context.WithCancel(ctx)
+		go m.watchTemplate(mineCtx, cancelMining, template)
+		mined := consensus.Mine(mineCtx, m.logger, block, m.workers)
+		cancelMining()
+		if mined == nil {
+			continue
+		}
+		response, err := m.client.SubmitBlock(ctx, mined)
+		if err != nil {
+			m.logger.Warn("block rejected", "node", m.address, "height", mined.Header.Number, "error", err)
+			continue
+		}
+		m.logger.Info("block accepted", "node", m.address, "hash", response.Hash, "height", mined.Header.Number)
+	}
+}
+
+// watchTemplate cancels mining once the node has moved on to a different
+// parent or pending tx set, so work is not wasted on a stale template.
+func (m *remoteMiner) watchTemplate(ctx context.Context, cancel context.CancelFunc, current client.BlockTemplate) {
+	ticker := time.NewTicker(m.poll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			template, err := m.client.MiningTemplate(ctx, m.account)
+			if err != nil {
+				continue
+			}
+			if template.Header.Parent != current.Header.Parent || len(template.Txs) != len(current.Txs) {
+				m.logger.Info("block template changed, restarting", "node", m.address, "height", template.Header.Number)
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/kparkins/yarbit/client"
+	"github.com/spf13/cobra"
+)
+
+func peersCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "peers",
+		Short: "Interact with the peers of a running node (list, add...)",
+		Run: func(cmd *cobra.Command, args []string) {
+
+		},
+	}
+	command.AddCommand(peersListCommand())
+	command.AddCommand(peersAddCommand())
+	return command
+}
+
+func peersListCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "list",
+		Short: "List the known peers of a node with their scores.",
+		Run: func(cmd *cobra.Command, args []string) {
+			result, err := remoteClient(cmd).Peers(context.Background())
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			sort.Slice(result.Peers, func(i, j int) bool {
+				return result.Peers[i].Address < result.Peers[j].Address
+			})
+			printResult(cmd, result, func() {
+				table := newTable()
+				fmt.Fprintln(table, "ADDRESS\tPROTOCOL\tSCORE\tBANNED\tLAST SEEN")
+				for _, peer := range result.Peers {
+					lastSeen := "never"
+					if !peer.Score.LastSeen.IsZero() {
+						lastSeen = peer.Score.LastSeen.Local().Format(time.RFC3339)
+					}
+					protocol := peer.Protocol
+					if protocol == "" {
+						protocol = client.ProtocolHttp
+					}
+					fmt.Fprintf(table, "%s\t%s\t%d\t%t\t%s\n", peer.Address, protocol, peer.Score.Score, peer.Banned, lastSeen)
+				}
+				table.Flush()
+			})
+		},
+	}
+	addRemoteFlags(command, defaultNodeAddress())
+	return command
+}
+
+func peersAddCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "add <peer>",
+		Short: "Introduce the peer at host:port or url to a node.",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx := context.Background()
+			// The node only accepts a peer whose node info matches what the
+			// peer itself reports, so the handshake is made with that info.
+			info, err := client.New(args[0], remoteOptions(cmd)).NodeInfo(ctx)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error fetching node info from %s: %v\n", args[0], err)
+				os.Exit(1)
+			}
+			result, err := remoteClient(cmd).AddPeer(ctx, info)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			printResult(cmd, result, func() {
+				fmt.Printf("Peer %s added: %s\n", info.Peer.SocketAddress(), result.Message)
+			})
+		},
+	}
+	addRemoteFlags(command, defaultNodeAddress())
+	return command
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"text/tabwriter"
+
+	"github.com/kparkins/yarbit/client"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const flagJson = "json"
+
+// defaultNodeAddress is where `yarbit run` listens without any flags.
+func defaultNodeAddress() string {
+	defaults := defaultRunConfig()
+	return fmt.Sprintf("%s:%d", defaults.Ip, defaults.Port)
+}
+
+// addRemoteFlags adds the flags of commands that talk to a running node over
+// its HTTP API.
+func addRemoteFlags(command *cobra.Command, defaultNode string) {
+	command.Flags().String(flagNode, defaultNode, "host:port or url of the node")
+	command.Flags().String(flagApiKey, "", "api key sent to the node, if it requires one")
+	addRemoteTlsFlags(command)
+	command.Flags().Bool(flagJson, false, "print the node's response as JSON")
+}
+
+// addRemoteTlsFlags adds the flags read by remoteHttpClient.
+func addRemoteTlsFlags(command *cobra.Command) {
+	command.Flags().String(flagTlsCa, "", "path to a PEM CA bundle trusted for the node's certificate")
+	command.Flags().String(flagTlsCert, "", "path to a PEM client certificate, for nodes that require mutual tls")
+	command.Flags().String(flagTlsKey, "", "path to the PEM private key of --tls-cert")
+}
+
+// remoteClient returns a client for the node set with --node. It exits if
+// the tls flags cannot be loaded.
+func remoteClient(cmd *cobra.Command) *client.Client {
+	address, _ := cmd.Flags().GetString(flagNode)
+	return client.New(address, remoteOptions(cmd))
+}
+
+func remoteOptions(cmd *cobra.Command) client.Options {
+	apiKey, _ := cmd.Flags().GetString(flagApiKey)
+	httpClient, err := remoteHttpClient(cmd)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	options := client.DefaultOptions()
+	options.ApiKey = apiKey
+	options.HttpClient = httpClient
+	return options
+}
+
+// remoteHttpClient applies the tls flags, returning nil to use the client's
+// default when none are set.
+func remoteHttpClient(cmd *cobra.Command) (*http.Client, error) {
+	ca, _ := cmd.Flags().GetString(flagTlsCa)
+	cert, _ := cmd.Flags().GetString(flagTlsCert)
+	key, _ := cmd.Flags().GetString(flagTlsKey)
+	if ca == "" && cert == "" && key == "" {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cert != "" || key != "" {
+		certificate, err := tls.LoadX509KeyPair(cert, key)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load tls certificate")
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	if ca != "" {
+		content, err := ioutil.ReadFile(ca)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read tls ca")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(content) {
+			return nil, fmt.Errorf("no certificates found in tls ca %s", ca)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Timeout: client.DefaultTimeout, Transport: transport}, nil
+}
+
+// printResult writes result as indented JSON when --json is set and calls
+// pretty otherwise.
+func printResult(cmd *cobra.Command, result interface{}, pretty func()) {
+	if asJson, _ := cmd.Flags().GetBool(flagJson); asJson {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(result); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	pretty()
+}
+
+func newTable() *tabwriter.Writer {
+	return tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+}
@@ -2,10 +2,10 @@ package main
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
-	"strconv"
-	"strings"
 
+	"github.com/kparkins/yarbit/database"
 	"github.com/kparkins/yarbit/node"
 	"github.com/spf13/cobra"
 )
@@ -13,57 +13,71 @@ import (
 const flagIp = "ip"
 const flagPort = "port"
 const flagBootstrap = "bootstrap"
+const flagLogFormat = "log-format"
+const flagLogLevel = "log-level"
+const flagLogComponents = "log-components"
 
 func runCommand() *cobra.Command {
 	command := &cobra.Command{
 		Use:   "run",
 		Short: "Launches the Yarbit node and its HTTP API.",
+		Long:  "Launches the Yarbit node and its HTTP API.\n\n" + configPrecedence,
 		Run: func(cmd *cobra.Command, args []string) {
-			dataDir, _ := cmd.Flags().GetString(flagDataDir)
-			bootstrapNode, _ := cmd.Flags().GetString(flagBootstrap)
-			ip, _ := cmd.Flags().GetString(flagIp)
-			port, _ := cmd.Flags().GetUint64(flagPort)
-
-			bootstrapIp, bootstrapPort := getBoostrapIpAndPort(bootstrapNode)
-			bootstrap := node.PeerNode{
-				IpAddress:   bootstrapIp,
-				Port:        bootstrapPort,
-				IsBootstrap: true,
-				IsActive:    true,
-			}
-			config := node.Config{
-				DataDir:      dataDir,
-				IpAddress:    ip,
-				Port:         port,
-				Protocol:     "http",
-				Bootstrap:    bootstrap,
-				MinerAccount: "miner",
-			}
-			server := node.New(config)
-			err := server.Run()
-			if err != nil {
+			if err := runNode(cmd); err != nil {
 				fmt.Fprintln(os.Stderr, err)
 				os.Exit(1)
 			}
-
 		},
 	}
-	command.Flags().String(flagBootstrap, "", "ip:port of the bootstrap node. If empty, defaults to being the bootstrap node.")
-	addDefaultRequiredFlags(command)
-	command.Flags().String(flagIp, "127.0.0.1", "the ip of the node")
-	command.Flags().Uint64(flagPort, uint64(80), "the port of the node")
+	addRunConfigFlags(command)
 	return command
 }
 
-func getBoostrapIpAndPort(node string) (string, uint64) {
-	parts := strings.Split(node, ":")
-	if len(parts) != 2 {
-		return "", 0
+// runNode runs the node until it is stopped. It returns instead of exiting so
+// that a temporary dev data directory is always removed.
+func runNode(cmd *cobra.Command) error {
+	runConfig, err := loadRunConfig(cmd)
+	if err != nil {
+		return err
+	}
+	if runConfig.Dev {
+		cleanup, err := prepareDevDataDir(&runConfig)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
 	}
-	port, err := strconv.ParseUint(parts[1], 10, 64)
+	config, err := runConfig.nodeConfig()
 	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		return "", 0
+		return err
+	}
+	return node.New(config).Run()
+}
+
+// prepareDevDataDir sets up a development chain. Without an explicit data
+// directory a temporary one is created and removed again by the returned
+// cleanup function.
+func prepareDevDataDir(config *runConfig) (func(), error) {
+	cleanup := func() {}
+	if config.DataDir == "" {
+		dir, err := ioutil.TempDir("", "yarbit-dev-")
+		if err != nil {
+			return cleanup, err
+		}
+		config.DataDir = dir
+		cleanup = func() {
+			os.RemoveAll(dir)
+		}
+	}
+	if err := database.InitDataDir(config.DataDir, database.DevGenesis()); err != nil {
+		cleanup()
+		return func() {}, err
+	}
+	config.Mining = true
+	fmt.Printf("Development chain in %s\n", config.DataDir)
+	fmt.Printf("Prefunded accounts:\n")
+	for _, account := range database.DevAccounts {
+		fmt.Printf("%10s: %10d\n", account, database.DevAccountBalance)
 	}
-	return parts[0], port
+	return cleanup, nil
 }
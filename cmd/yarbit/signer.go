@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/kparkins/yarbit/consensus"
+	"github.com/spf13/cobra"
+)
+
+const flagOut = "out"
+
+func signerCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "signer",
+		Short: "Manage proof of authority signer keys (new...)",
+		Run: func(cmd *cobra.Command, args []string) {
+
+		},
+	}
+	command.AddCommand(signerNewCommand())
+	return command
+}
+
+func signerNewCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "new",
+		Short: "Generate a new signer key and print its public key for the genesis file.",
+		Run: func(cmd *cobra.Command, args []string) {
+			out, _ := cmd.Flags().GetString(flagOut)
+			private, public, err := consensus.GenerateSignerKey()
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			if err := ioutil.WriteFile(out, []byte(private+"\n"), 0600); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			fmt.Printf("Signer key written to %s\n", out)
+			fmt.Printf("Public key: %s\n", public)
+		},
+	}
+	command.Flags().String(flagOut, "", "Path to write the private key to.")
+	command.MarkFlagRequired(flagOut)
+	return command
+}
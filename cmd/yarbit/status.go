@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+func statusCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "status",
+		Short: "Show the chain tip, peers and sync progress of a running node.",
+		Run: func(cmd *cobra.Command, args []string) {
+			status, err := remoteClient(cmd).Status(context.Background())
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			printResult(cmd, status, func() {
+				fmt.Printf("Block:    %d %s\n", status.Number, status.Hash)
+				fmt.Printf("Peers:    %d\n", len(status.KnownPeers))
+				fmt.Printf("Pending:  %d txs\n", len(status.PendingTxs))
+				if status.Sync.Syncing {
+					fmt.Printf("Syncing:  %d of %d from %s\n", status.Sync.CurrentHeight, status.Sync.HighestHeight, status.Sync.Peer)
+				} else {
+					fmt.Printf("Syncing:  no\n")
+				}
+			})
+		},
+	}
+	addRemoteFlags(command, defaultNodeAddress())
+	return command
+}
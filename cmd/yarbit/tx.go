@@ -1,11 +1,14 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"github.com/kparkins/yarbit/database"
-	"github.com/spf13/cobra"
 	"os"
 	"time"
+
+	"github.com/kparkins/yarbit/client"
+	"github.com/kparkins/yarbit/database"
+	"github.com/spf13/cobra"
 )
 
 const flagTo = "to"
@@ -16,12 +19,13 @@ const flagValue = "value"
 func txCommand() *cobra.Command {
 	command := &cobra.Command{
 		Use:   "tx",
-		Short: "Interact with txs (add...)",
+		Short: "Interact with txs (add, send...)",
 		Run: func(cmd *cobra.Command, args []string) {
 
 		},
 	}
 	command.AddCommand(txAddCommand())
+	command.AddCommand(txSendCommand())
 	return command
 }
 
@@ -43,7 +47,7 @@ func txAddCommand() *cobra.Command {
 			tx := database.NewTx(database.NewAccount(from), database.NewAccount(to), value, data)
 			block := database.NewBlock(
 				state.LatestBlockHash(),
-				state.LatestBlockNumber()+1,
+				state.NextBlockNumber(),
 				uint64(time.Now().Unix()),
 				[]database.Tx{tx},
 			)
@@ -70,3 +74,43 @@ func txAddCommand() *cobra.Command {
 
 	return command
 }
+
+func txSendCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "send",
+		Short: "Submit a transaction to a running node's mempool.",
+		Run: func(cmd *cobra.Command, args []string) {
+			from, _ := cmd.Flags().GetString(flagFrom)
+			to, _ := cmd.Flags().GetString(flagTo)
+			value, _ := cmd.Flags().GetUint(flagValue)
+			data, _ := cmd.Flags().GetString(flagData)
+			hash, err := remoteClient(cmd).AddTx(context.Background(), client.TxAddRequest{
+				From:  from,
+				To:    to,
+				Value: value,
+				Data:  data,
+			})
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			printResult(cmd, client.TxAddResponse{Hash: hash}, func() {
+				fmt.Printf("TX added to the mempool: %s\n", hash)
+			})
+		},
+	}
+	addRemoteFlags(command, defaultNodeAddress())
+
+	command.Flags().String(flagFrom, "", "From what account to send tokens.")
+	command.MarkFlagRequired(flagFrom)
+
+	command.Flags().String(flagTo, "", "To what account to send tokens.")
+	command.MarkFlagRequired(flagTo)
+
+	command.Flags().Uint(flagValue, 0, "The amount of tokens to send.")
+	command.MarkFlagRequired(flagValue)
+
+	command.Flags().String(flagData, "", "Data to send with the transaction. 'reward' current used.")
+
+	return command
+}
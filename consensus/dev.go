@@ -0,0 +1,55 @@
+package consensus
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kparkins/yarbit/database"
+)
+
+// Dev seals every block instantly and accepts any block that extends chain
+// and pays a single reward. It is only meant for throwaway development chains.
+type Dev struct {
+	chain Chain
+}
+
+func NewDev(chain Chain) *Dev {
+	return &Dev{chain: chain}
+}
+
+func (d *Dev) Name() string {
+	return database.ConsensusDev
+}
+
+func (d *Dev) Prepare(header *database.BlockHeader) error {
+	header.Nonce = 0
+	header.Signature = nil
+	return nil
+}
+
+func (d *Dev) Seal(ctx context.Context, block *database.Block) (*database.Block, error) {
+	return block.Clone(), nil
+}
+
+func (d *Dev) Verify(block *database.Block) error {
+	if parent := d.chain.LatestBlockHash(); block.Header.Parent != parent {
+		return &database.OutOfOrderBlockError{Err: fmt.Errorf("block %d does not extend %s", block.Header.Number, parent)}
+	}
+	if next := d.chain.NextBlockNumber(); block.Header.Number != next {
+		return &database.OutOfOrderBlockError{Err: fmt.Errorf("block %d is not the next block %d", block.Header.Number, next)}
+	}
+	// The miner is paid the block reward, any reward tx would pay a second one.
+	if block.Header.Miner == "" {
+		return fmt.Errorf("block %d has no miner to reward", block.Header.Number)
+	}
+	for _, tx := range block.Txs {
+		if tx.IsReward() {
+			return fmt.Errorf("block %d pays more than one reward", block.Header.Number)
+		}
+	}
+	return nil
+}
+
+func (d *Dev) VerifyHeader(hash database.Hash, header *database.BlockHeader) error {
+	return nil
+}
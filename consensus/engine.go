@@ -0,0 +1,61 @@
+package consensus
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kparkins/yarbit/database"
+	"github.com/kparkins/yarbit/logging"
+)
+
+var ErrNotSigner = fmt.Errorf("node is not allowed to seal this block")
+
+// Engine decides who may produce blocks and how they prove it.
+type Engine interface {
+	// Name identifies the engine, as used in the genesis file.
+	Name() string
+	// Prepare fills in the consensus fields of a new block's header.
+	Prepare(header *database.BlockHeader) error
+	// Seal returns a sealed copy of block. It blocks until the block is sealed
+	// and returns nil without error if ctx is cancelled first. Engines return
+	// ErrNotSigner when this node cannot seal the block.
+	Seal(ctx context.Context, block *database.Block) (*database.Block, error)
+	// Verify checks that block was sealed according to the engine's rules.
+	Verify(block *database.Block) error
+	// VerifyHeader checks what can be checked of a block from its claimed
+	// hash and header alone, before its body has been downloaded.
+	VerifyHeader(hash database.Hash, header *database.BlockHeader) error
+}
+
+// Chain is the part of the local chain that engines verify blocks against.
+type Chain interface {
+	LatestBlockHash() database.Hash
+	NextBlockNumber() uint64
+}
+
+type Options struct {
+	// Account is the account this node produces blocks as.
+	Account database.Account
+	// MiningWorkers is the number of proof of work mining workers.
+	MiningWorkers int
+	// SignerKeyFile is the path of the proof of authority signing key.
+	SignerKeyFile string
+	Chain         Chain
+	Logger        logging.Logger
+}
+
+// New creates the engine described by the genesis consensus config.
+func New(config database.ConsensusConfig, options Options) (Engine, error) {
+	if options.Logger == nil {
+		options.Logger = logging.Default()
+	}
+	switch config.Engine {
+	case "", database.ConsensusProofOfWork:
+		return NewProofOfWork(options.MiningWorkers, options.Logger), nil
+	case database.ConsensusProofOfAuthority:
+		return NewProofOfAuthority(config.Signers, options.Account, options.SignerKeyFile)
+	case database.ConsensusDev:
+		return NewDev(options.Chain), nil
+	}
+	return nil, fmt.Errorf("unknown consensus engine '%s'", config.Engine)
+}
@@ -0,0 +1,19 @@
+package consensus
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var MiningAttempts = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "yarbit",
+	Subsystem: "miner",
+	Name:      "attempts_total",
+	Help:      "Number of block hashes computed while mining.",
+})
+
+var MiningHashRate = prometheus.NewGauge(prometheus.GaugeOpts{
+	Namespace: "yarbit",
+	Subsystem: "miner",
+	Name:      "hash_rate",
+	Help:      "Hashes per second computed by the miner over the last report interval.",
+})
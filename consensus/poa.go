@@ -0,0 +1,140 @@
+package consensus
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/kparkins/yarbit/database"
+	"github.com/pkg/errors"
+)
+
+// ProofOfAuthority lets a fixed set of signers from the genesis file take
+// turns producing blocks. The signer for block n is the n-th signer, in
+// account order, modulo the number of signers.
+type ProofOfAuthority struct {
+	signers []database.Account
+	keys    map[database.Account]ed25519.PublicKey
+	account database.Account
+	key     ed25519.PrivateKey
+}
+
+func NewProofOfAuthority(signers map[database.Account]string, account database.Account, keyFile string) (*ProofOfAuthority, error) {
+	if len(signers) == 0 {
+		return nil, fmt.Errorf("proof of authority requires at least one signer")
+	}
+	p := &ProofOfAuthority{
+		signers: make([]database.Account, 0, len(signers)),
+		keys:    make(map[database.Account]ed25519.PublicKey, len(signers)),
+		account: account,
+	}
+	for signer, encoded := range signers {
+		key, err := hex.DecodeString(encoded)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid public key for signer %s", signer)
+		}
+		p.signers = append(p.signers, signer)
+		p.keys[signer] = key
+	}
+	sort.Slice(p.signers, func(i, j int) bool {
+		return p.signers[i] < p.signers[j]
+	})
+	if keyFile == "" {
+		return p, nil
+	}
+	key, err := LoadSignerKey(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	public, ok := p.keys[account]
+	if !ok {
+		return nil, fmt.Errorf("%s is not a signer", account)
+	}
+	if !public.Equal(key.Public()) {
+		return nil, fmt.Errorf("signer key does not match the genesis key of %s", account)
+	}
+	p.key = key
+	return p, nil
+}
+
+func (p *ProofOfAuthority) Name() string {
+	return database.ConsensusProofOfAuthority
+}
+
+func (p *ProofOfAuthority) signerFor(number uint64) database.Account {
+	return p.signers[number%uint64(len(p.signers))]
+}
+
+func (p *ProofOfAuthority) Prepare(header *database.BlockHeader) error {
+	header.Nonce = 0
+	header.Signature = nil
+	header.Miner = p.signerFor(header.Number)
+	return nil
+}
+
+func (p *ProofOfAuthority) Seal(ctx context.Context, block *database.Block) (*database.Block, error) {
+	if p.key == nil || p.signerFor(block.Header.Number) != p.account {
+		return nil, ErrNotSigner
+	}
+	sealed := block.Clone()
+	sealed.Header.Miner = p.account
+	hash, err := sealed.SealHash()
+	if err != nil {
+		return nil, err
+	}
+	sealed.Header.Signature = ed25519.Sign(p.key, hash[:])
+	return sealed, nil
+}
+
+func (p *ProofOfAuthority) Verify(block *database.Block) error {
+	expected := p.signerFor(block.Header.Number)
+	if block.Header.Miner != expected {
+		return fmt.Errorf("block %d must be signed by %s, not %s", block.Header.Number, expected, block.Header.Miner)
+	}
+	hash, err := block.SealHash()
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(p.keys[expected], hash[:], block.Header.Signature) {
+		return fmt.Errorf("invalid signature on block %d", block.Header.Number)
+	}
+	return nil
+}
+
+func (p *ProofOfAuthority) VerifyHeader(hash database.Hash, header *database.BlockHeader) error {
+	expected := p.signerFor(header.Number)
+	if header.Miner != expected {
+		return fmt.Errorf("block %d must be signed by %s, not %s", header.Number, expected, header.Miner)
+	}
+	if len(header.Signature) != ed25519.SignatureSize {
+		return fmt.Errorf("invalid signature on block %d", header.Number)
+	}
+	return nil
+}
+
+// GenerateSignerKey creates a new signing key, returning the hex encoded
+// private and public keys.
+func GenerateSignerKey() (string, string, error) {
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		return "", "", err
+	}
+	return hex.EncodeToString(private.Seed()), hex.EncodeToString(public), nil
+}
+
+// LoadSignerKey reads a hex encoded ed25519 seed from path.
+func LoadSignerKey(path string) (ed25519.PrivateKey, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read signer key")
+	}
+	seed, err := hex.DecodeString(strings.TrimSpace(string(content)))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("invalid signer key in %s", path)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
@@ -0,0 +1,161 @@
+package consensus
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/kparkins/yarbit/database"
+	"github.com/kparkins/yarbit/logging"
+)
+
+const hashRateInterval = 5 * time.Second
+
+// attemptBatch is how many nonces a worker tries between checking for
+// cancellation and publishing its attempt count.
+const attemptBatch = 4096
+
+type ProofOfWork struct {
+	workers int
+	logger  logging.Logger
+}
+
+func NewProofOfWork(workers int, logger logging.Logger) *ProofOfWork {
+	if workers < 1 {
+		workers = 1
+	}
+	return &ProofOfWork{workers: workers, logger: logger}
+}
+
+func (p *ProofOfWork) Name() string {
+	return database.ConsensusProofOfWork
+}
+
+func (p *ProofOfWork) Prepare(header *database.BlockHeader) error {
+	header.Nonce = 0
+	header.Signature = nil
+	return nil
+}
+
+func (p *ProofOfWork) Seal(ctx context.Context, block *database.Block) (*database.Block, error) {
+	return Mine(ctx, p.logger, block, p.workers), nil
+}
+
+func (p *ProofOfWork) Verify(block *database.Block) error {
+	hash, err := block.Hash()
+	if err != nil {
+		return err
+	}
+	if !database.IsBlockHashValid(hash) {
+		return fmt.Errorf("block %s does not meet the target", hash)
+	}
+	return nil
+}
+
+func (p *ProofOfWork) VerifyHeader(hash database.Hash, header *database.BlockHeader) error {
+	if !database.IsBlockHashValid(hash) {
+		return fmt.Errorf("block %s does not meet the target", hash)
+	}
+	return nil
+}
+
+// Mine searches for a nonce that makes pending a valid block using the given
+// number of workers. It returns nil if ctx is cancelled first.
+func Mine(ctx context.Context, logger logging.Logger, pending *database.Block, workers int) *database.Block {
+	if len(pending.Txs) <= 0 {
+		logger.Warn("cannot mine an empty block")
+		return nil
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	c, cancelWorkers := context.WithCancel(ctx)
+	defer cancelWorkers()
+
+	var attempts uint64
+	start := time.Now()
+	found := make(chan *database.Block, workers)
+	for i := 0; i < workers; i++ {
+		go mineWorker(c, logger, pending.Clone(), uint64(i), uint64(workers), &attempts, found)
+	}
+
+	ticker := time.NewTicker(hashRateInterval)
+	defer ticker.Stop()
+	lastAttempts, lastReport := uint64(0), start
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("mining cancelled", "height", pending.Header.Number)
+			MiningHashRate.Set(0)
+			return nil
+		case now := <-ticker.C:
+			total := atomic.LoadUint64(&attempts)
+			rate := float64(total-lastAttempts) / now.Sub(lastReport).Seconds()
+			MiningHashRate.Set(rate)
+			logger.Debug("mining progress", "height", pending.Header.Number, "attempts", total, "hash_rate", rate)
+			lastAttempts, lastReport = total, now
+		case block := <-found:
+			cancelWorkers()
+			MiningHashRate.Set(0)
+			hash, err := block.Hash()
+			if err != nil {
+				logger.Error("error hashing mined block", "error", err)
+				return nil
+			}
+			elapsed := time.Since(start)
+			total := atomic.LoadUint64(&attempts)
+			logger.Info("mined new block",
+				"hash", hash,
+				"height", block.Header.Number,
+				"nonce", block.Header.Nonce,
+				"created", block.Header.Time,
+				"miner", block.Header.Miner,
+				"parent", block.Header.Parent,
+				"duration", elapsed,
+				"attempts", total,
+				"hash_rate", float64(total)/elapsed.Seconds(),
+			)
+			return block
+		}
+	}
+}
+
+// mineWorker searches the nonces first, first+stride, first+2*stride, ... of
+// the full 64 bit space. When its share is exhausted it rolls the block time
+// forward and starts over with a fresh header.
+func mineWorker(ctx context.Context, logger logging.Logger, block *database.Block, first, stride uint64, attempts *uint64, found chan<- *database.Block) {
+	for {
+		hasher, err := database.NewBlockHasher(block)
+		if err != nil {
+			logger.Error("error serializing pending block", "error", err)
+			return
+		}
+		batch := uint64(0)
+		for nonce := first; ; nonce += stride {
+			if batch++; batch == attemptBatch {
+				atomic.AddUint64(attempts, batch)
+				MiningAttempts.Add(float64(batch))
+				batch = 0
+				select {
+				case <-ctx.Done():
+					return
+				default:
+				}
+			}
+			if database.IsBlockHashValid(hasher.Hash(nonce)) {
+				atomic.AddUint64(attempts, batch)
+				MiningAttempts.Add(float64(batch))
+				block.Header.Nonce = nonce
+				found <- block
+				return
+			}
+			if nonce > math.MaxUint64-stride {
+				break
+			}
+		}
+		block.Header.Time++
+		logger.Debug("nonce space exhausted, rolling block time", "worker", first, "time", block.Header.Time)
+	}
+}
@@ -14,18 +14,21 @@ type BlockFileEntry struct {
 type BlockHeader struct {
 	Parent Hash    `json:"parent"`
 	Number uint64  `json:"number"`
-	Nonce  int32   `json:"nonce"`
+	Nonce  uint64  `json:"nonce"`
 	Time   uint64  `json:"time"`
 	Miner  Account `json:"miner"`
+	// Signature is set by consensus engines that seal blocks by signing them.
+	Signature []byte `json:"signature,omitempty"`
 }
 
 func (h BlockHeader) Clone() BlockHeader {
 	return BlockHeader{
-		Parent: h.Parent.Clone(),
-		Number: h.Number,
-		Nonce:  h.Nonce,
-		Time:   h.Time,
-		Miner:  h.Miner,
+		Parent:    h.Parent.Clone(),
+		Number:    h.Number,
+		Nonce:     h.Nonce,
+		Time:      h.Time,
+		Miner:     h.Miner,
+		Signature: append([]byte(nil), h.Signature...),
 	}
 }
 
@@ -55,15 +58,15 @@ func (b *Block) DebugString() string {
 	}
 	out := struct {
 		Header BlockHeader `json:"header"`
-		Hashes    []Hash        `json:"payload"`
-	} {
+		Hashes []Hash      `json:"payload"`
+	}{
 		Header: b.Header,
 		Hashes: txs,
 	}
 	if json, err := json.Marshal(&out); err == nil {
-		return string(json) 
+		return string(json)
 	}
-	return "" 
+	return ""
 }
 
 func (b *Block) Hash() (Hash, error) {
@@ -74,8 +77,16 @@ func (b *Block) Hash() (Hash, error) {
 	return sha256.Sum256(encoded), nil
 }
 
+// SealHash is the hash of the block without its signature, which is what
+// signing consensus engines sign.
+func (b *Block) SealHash() (Hash, error) {
+	unsigned := *b
+	unsigned.Header.Signature = nil
+	return unsigned.Hash()
+}
+
 func (b *Block) Clone() *Block {
-	txs := make([]Tx, 0, len(b.Txs))
+	txs := make([]Tx, len(b.Txs))
 	copy(txs, b.Txs)
 	return &Block{
 		Header: b.Header.Clone(),
@@ -92,6 +103,15 @@ func init() {
 	}
 }
 
+// MiningTarget is the largest hash that satisfies MiningDifficulty.
+func MiningTarget() Hash {
+	var target Hash
+	for i := MiningDifficulty; i < len(target); i++ {
+		target[i] = 0xFF
+	}
+	return target
+}
+
 func IsBlockHashValid(hash Hash) bool {
 	return bytes.Equal(hash[:MiningDifficulty], MiningDifficultyBytes)
 }
@@ -2,9 +2,12 @@ package database
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"os"
 	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 const AfterGenesis = ""
@@ -12,7 +15,7 @@ const AfterGenesis = ""
 type BlockStore interface {
 	Write(blocks ...*Block) (Hash, error)
 	Read(after string, limit uint64) ([]Block, error)
-	Stream(after string, blockStream chan<- Block)
+	Stream(ctx context.Context, after string, blockStream chan<- Block)
 }
 
 type FileBlockStore struct {
@@ -30,10 +33,13 @@ func NewFileBlockStore(file string) *FileBlockStore {
 func (f *FileBlockStore) Write(blocks ...*Block) (Hash, error) {
 	f.lock.Lock()
 	defer f.lock.Unlock()
+	timer := prometheus.NewTimer(BlockStoreWriteDuration)
+	defer timer.ObserveDuration()
 	file, err := os.OpenFile(f.file, os.O_APPEND|os.O_RDWR, os.ModePerm)
 	if err != nil {
 		return Hash{}, err
 	}
+	defer file.Close()
 	var hash Hash
 	for _, block := range blocks {
 		hash, err = block.Hash()
@@ -55,50 +61,80 @@ func (f *FileBlockStore) Write(blocks ...*Block) (Hash, error) {
 	return hash, nil
 }
 
-func (f *FileBlockStore) Stream(after string, blockStream chan<- Block) {
+// Stream sends every block after the given hash, reading the file in batches
+// the size of the channel buffer. The file is only locked while a batch is
+// read, not while it is sent. The channel is closed once all blocks are sent
+// or ctx is done.
+func (f *FileBlockStore) Stream(ctx context.Context, after string, blockStream chan<- Block) {
+	defer close(blockStream)
 	batch := uint64(cap(blockStream))
+	if batch == 0 {
+		batch = 1
+	}
+	file, err := os.OpenFile(f.file, os.O_RDONLY, os.ModePerm)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	f.lock.RLock()
+	err = f.seek(scanner, after)
+	f.lock.RUnlock()
+	if err != nil {
+		return
+	}
 	for {
-		blocks, err := f.Read(after, batch)
-		if err != nil || len(blocks) < 1 {
-			goto done
+		f.lock.RLock()
+		timer := prometheus.NewTimer(BlockStoreReadDuration)
+		blocks, err := f.scan(scanner, batch)
+		timer.ObserveDuration()
+		f.lock.RUnlock()
+		if err != nil {
+			return
 		}
 		for _, b := range blocks {
-			blockStream <- b
+			select {
+			case blockStream <- b:
+			case <-ctx.Done():
+				return
+			}
 		}
-		h, err := blocks[len(blocks)-1].Hash()
-		if err != nil || uint64(len(blocks)) < batch {
-			goto done
+		if uint64(len(blocks)) < batch {
+			return
 		}
-		after = h.String()
 	}
-done:
-	close(blockStream)
 }
 
 func (f *FileBlockStore) Read(after string, limit uint64) ([]Block, error) {
 	f.lock.RLock()
 	defer f.lock.RUnlock()
+	timer := prometheus.NewTimer(BlockStoreReadDuration)
+	defer timer.ObserveDuration()
 
-	blocks := make([]Block, 0)
 	file, err := os.OpenFile(f.file, os.O_RDONLY, os.ModePerm)
 	if err != nil {
 		return nil, err
 	}
+	defer file.Close()
 	scanner := bufio.NewScanner(file)
 	if err := f.seek(scanner, after); err != nil {
-		return blocks, err
+		return make([]Block, 0), err
 	}
+	return f.scan(scanner, limit)
+}
+
+// scan decodes up to limit blocks from scanner. The caller must hold the read
+// lock.
+func (f *FileBlockStore) scan(scanner *bufio.Scanner, limit uint64) ([]Block, error) {
+	blocks := make([]Block, 0)
 	for i := uint64(0); i < limit && scanner.Scan(); i++ {
 		var blockEntry BlockFileEntry
-		if err = scanner.Err(); err != nil {
-			return blocks, err
-		}
-		if err = json.Unmarshal(scanner.Bytes(), &blockEntry); err != nil {
+		if err := json.Unmarshal(scanner.Bytes(), &blockEntry); err != nil {
 			return blocks, err
 		}
 		blocks = append(blocks, *blockEntry.Block)
 	}
-	return blocks, nil
+	return blocks, scanner.Err()
 }
 
 func (f *FileBlockStore) seek(scanner *bufio.Scanner, after string) error {
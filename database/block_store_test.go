@@ -0,0 +1,56 @@
+package database
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileBlockStoreStream(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "block.db")
+	if err := ioutil.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	store := NewFileBlockStore(file)
+	hashes := make([]Hash, 0)
+	var parent Hash
+	for number := uint64(0); number < 10; number++ {
+		block := NewBlock(parent, number, number, []Tx{NewTx("alice", "bob", uint(number), "")})
+		hash, err := store.Write(block)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashes = append(hashes, hash)
+		parent = hash
+	}
+
+	cases := []struct {
+		name  string
+		after string
+		size  int
+		first uint64
+	}{
+		{name: "from genesis", after: AfterGenesis, size: 3, first: 0},
+		{name: "after a block", after: hashes[3].String(), size: 3, first: 4},
+		{name: "batch dividing the blocks", after: hashes[1].String(), size: 4, first: 2},
+		{name: "unbuffered", after: hashes[6].String(), size: 0, first: 7},
+		{name: "after the last block", after: hashes[9].String(), size: 3, first: 10},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			stream := make(chan Block, c.size)
+			go store.Stream(context.Background(), c.after, stream)
+			expected := c.first
+			for block := range stream {
+				if block.Header.Number != expected {
+					t.Fatalf("expected block %d, got %d", expected, block.Header.Number)
+				}
+				expected++
+			}
+			if expected != uint64(len(hashes)) {
+				t.Fatalf("stream ended before block %d", expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package database
+
+// InvalidBlockError reports a block that can never be added to the chain, as
+// opposed to one that merely does not fit the current tip.
+type InvalidBlockError struct {
+	Err error
+}
+
+func (e *InvalidBlockError) Error() string {
+	return "invalid block: " + e.Err.Error()
+}
+
+func (e *InvalidBlockError) Unwrap() error {
+	return e.Err
+}
+
+// OutOfOrderBlockError reports a block that does not fit the current tip, e.g.
+// because another block was added first.
+type OutOfOrderBlockError struct {
+	Err error
+}
+
+func (e *OutOfOrderBlockError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *OutOfOrderBlockError) Unwrap() error {
+	return e.Err
+}
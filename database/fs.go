@@ -1,6 +1,7 @@
 package database
 
 import (
+	"encoding/json"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -10,13 +11,30 @@ func initDataDir(dataDir string) error {
 	if _, err := os.Stat(dataDir); !os.IsNotExist(err) {
 		return nil
 	}
+	return createDataDir(dataDir, []byte(GenesisJson))
+}
+
+// InitDataDir creates a data directory using genesis instead of the default
+// genesis file. Existing data directories are left untouched.
+func InitDataDir(dataDir string, genesis *Genesis) error {
+	if _, err := os.Stat(getGenesisFilePath(dataDir)); !os.IsNotExist(err) {
+		return nil
+	}
+	content, err := json.MarshalIndent(genesis, "", "  ")
+	if err != nil {
+		return err
+	}
+	return createDataDir(dataDir, content)
+}
+
+func createDataDir(dataDir string, genesis []byte) error {
 	dbDir := getDatabaseDirectoryPath(dataDir)
 	if err := os.MkdirAll(dbDir, os.ModePerm); err != nil {
 		return err
 	}
 	os.Chown(dbDir, os.Getuid(), os.Getuid())
 	genesisPath := getGenesisFilePath(dataDir)
-	if err := writeGenesisToDisk(genesisPath); err != nil {
+	if err := writeGenesisToDisk(genesisPath, genesis); err != nil {
 		return err
 	}
 	blockDbPath := getBlockDatabaseFilePath(dataDir)
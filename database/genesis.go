@@ -1,6 +1,7 @@
 package database
 
 import (
+	"crypto/sha256"
 	"encoding/json"
 	"io/ioutil"
 	"os"
@@ -16,10 +17,52 @@ const GenesisJson = `{
 }
 `
 
+const (
+	ConsensusProofOfWork      = "pow"
+	ConsensusProofOfAuthority = "poa"
+	ConsensusDev              = "dev"
+)
+
+const DevAccountBalance = 1000000
+
+var DevAccounts = []Account{"alice", "bob", "carol", "dave"}
+
+// DevGenesis returns the genesis of a throwaway development chain that seals
+// blocks instantly and prefunds DevAccounts.
+func DevGenesis() *Genesis {
+	balances := make(map[Account]uint, len(DevAccounts))
+	for _, account := range DevAccounts {
+		balances[account] = DevAccountBalance
+	}
+	return &Genesis{
+		GenesisTime: time.Now().UTC(),
+		ChainId:     "the-yarbit-dev-ledger",
+		Balances:    balances,
+		Consensus:   ConsensusConfig{Engine: ConsensusDev},
+	}
+}
+
+type ConsensusConfig struct {
+	Engine string `json:"engine"`
+	// Signers maps each proof of authority signer to its hex encoded ed25519
+	// public key.
+	Signers map[Account]string `json:"signers,omitempty"`
+}
+
 type Genesis struct {
 	GenesisTime time.Time        `json:"genesis_time"`
 	ChainId     string           `json:"chain_id"`
 	Balances    map[Account]uint `json:"balances"`
+	Consensus   ConsensusConfig  `json:"consensus"`
+}
+
+// Hash identifies the chain a genesis file starts.
+func (g *Genesis) Hash() (Hash, error) {
+	encoded, err := json.Marshal(g)
+	if err != nil {
+		return Hash{}, err
+	}
+	return sha256.Sum256(encoded), nil
 }
 
 func LoadGenesis(path string) (*Genesis, error) {
@@ -34,8 +77,8 @@ func LoadGenesis(path string) (*Genesis, error) {
 	return genesis, nil
 }
 
-func writeGenesisToDisk(path string) error {
-	if err := ioutil.WriteFile(path, []byte(GenesisJson), 0644); err != nil {
+func writeGenesisToDisk(path string, content []byte) error {
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
 		return err
 	}
 	return os.Chown(path, os.Getuid(), os.Getgid())
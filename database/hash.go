@@ -3,6 +3,7 @@ package database
 import (
 	"bytes"
 	"encoding/hex"
+	"fmt"
 )
 
 type Hash [32]byte
@@ -15,6 +16,15 @@ func (h Hash) String() string {
 	return hex.EncodeToString(h[:])
 }
 
+func ParseHash(s string) (Hash, error) {
+	var hash Hash
+	if hex.DecodedLen(len(s)) != len(hash) {
+		return hash, fmt.Errorf("invalid hash length %d", len(s))
+	}
+	_, err := hex.Decode(hash[:], []byte(s))
+	return hash, err
+}
+
 func (h *Hash) UnmarshalText(data []byte) error {
 	_, err := hex.Decode(h[:], data)
 	return err
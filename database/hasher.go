@@ -0,0 +1,54 @@
+package database
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"math"
+	"strconv"
+)
+
+var nonceMarker = []byte(`"nonce":` + strconv.FormatUint(math.MaxUint64, 10))
+
+// BlockHasher computes block hashes for different nonces without marshalling
+// the block each time. The block is serialized once and split around the
+// nonce, so Hash(nonce) matches Block.Hash() with that nonce set.
+type BlockHasher struct {
+	prefix []byte
+	suffix []byte
+	digest hash.Hash
+	buffer []byte
+}
+
+func NewBlockHasher(block *Block) (*BlockHasher, error) {
+	marked := *block
+	marked.Header.Nonce = math.MaxUint64
+	encoded, err := json.Marshal(marked)
+	if err != nil {
+		return nil, err
+	}
+	i := bytes.Index(encoded, nonceMarker)
+	if i < 0 {
+		return nil, fmt.Errorf("nonce missing from serialized block")
+	}
+	prefixLength := i + len(`"nonce":`)
+	return &BlockHasher{
+		prefix: encoded[:prefixLength],
+		suffix: encoded[i+len(nonceMarker):],
+		digest: sha256.New(),
+		buffer: make([]byte, 0, 20),
+	}, nil
+}
+
+func (b *BlockHasher) Hash(nonce uint64) Hash {
+	var hash Hash
+	b.buffer = strconv.AppendUint(b.buffer[:0], nonce, 10)
+	b.digest.Reset()
+	b.digest.Write(b.prefix)
+	b.digest.Write(b.buffer)
+	b.digest.Write(b.suffix)
+	b.digest.Sum(hash[:0])
+	return hash
+}
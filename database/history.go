@@ -0,0 +1,90 @@
+package database
+
+import (
+	"fmt"
+)
+
+const BalanceSnapshotInterval = 100
+
+type balanceHistory struct {
+	hashes    []Hash
+	diffs     []map[Account]int64
+	snapshots map[uint64]map[Account]uint
+}
+
+func newBalanceHistory() *balanceHistory {
+	return &balanceHistory{
+		hashes:    make([]Hash, 0),
+		diffs:     make([]map[Account]int64, 0),
+		snapshots: make(map[uint64]map[Account]uint),
+	}
+}
+
+func (h *balanceHistory) height() uint64 {
+	return uint64(len(h.diffs))
+}
+
+func (h *balanceHistory) record(number uint64, hash Hash, before, after map[Account]uint) error {
+	if number != h.height() {
+		return fmt.Errorf("balance history expected block %d but got %d", h.height(), number)
+	}
+	diff := make(map[Account]int64)
+	for account, balance := range after {
+		if delta := int64(balance) - int64(before[account]); delta != 0 {
+			diff[account] = delta
+		}
+	}
+	h.hashes = append(h.hashes, hash)
+	h.diffs = append(h.diffs, diff)
+	if number%BalanceSnapshotInterval == 0 {
+		h.snapshots[number] = copyBalances(after)
+	}
+	return nil
+}
+
+// truncate forgets the blocks from number on.
+func (h *balanceHistory) truncate(number uint64) {
+	if number >= h.height() {
+		return
+	}
+	h.hashes = h.hashes[:number]
+	h.diffs = h.diffs[:number]
+	for height := range h.snapshots {
+		if height >= number {
+			delete(h.snapshots, height)
+		}
+	}
+}
+
+func (h *balanceHistory) at(height uint64) (Hash, map[Account]uint, error) {
+	if height >= h.height() {
+		return Hash{}, nil, fmt.Errorf("no block at height %d", height)
+	}
+	base := height - height%BalanceSnapshotInterval
+	snapshot, ok := h.snapshots[base]
+	if !ok {
+		return Hash{}, nil, fmt.Errorf("missing balance snapshot at height %d", base)
+	}
+	balances := make(map[Account]int64, len(snapshot))
+	for account, balance := range snapshot {
+		balances[account] = int64(balance)
+	}
+	for i := base + 1; i <= height; i++ {
+		for account, delta := range h.diffs[i] {
+			balances[account] += delta
+		}
+	}
+	result := make(map[Account]uint, len(balances))
+	for account, balance := range balances {
+		result[account] = uint(balance)
+	}
+	return h.hashes[height], result, nil
+}
+
+func copyBalances(balances map[Account]uint) map[Account]uint {
+	result := make(map[Account]uint, len(balances))
+	for k, v := range balances {
+		result[k] = v
+	}
+	return result
+}
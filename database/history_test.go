@@ -0,0 +1,63 @@
+package database
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// testTransfer moves value between two accounts and pays the miner in the
+// block at number.
+func testTransfer(balances map[Account]uint, number uint64) {
+	from, to := DevAccounts[number%4], DevAccounts[(number+1)%4]
+	value := uint(number % 7)
+	balances[from] -= value
+	balances[to] += value
+	balances[Account(fmt.Sprintf("miner-%d", number%3))] += BlockReward
+}
+
+// replay returns the balances after the block at height by applying every
+// block since genesis.
+func replay(genesis map[Account]uint, height uint64) map[Account]uint {
+	balances := copyBalances(genesis)
+	for number := uint64(0); number <= height; number++ {
+		testTransfer(balances, number)
+	}
+	return balances
+}
+
+func TestBalanceHistoryMatchesReplay(t *testing.T) {
+	genesis := DevGenesis().Balances
+	history := newBalanceHistory()
+	blocks := uint64(2*BalanceSnapshotInterval + 50)
+	balances := copyBalances(genesis)
+	for number := uint64(0); number < blocks; number++ {
+		before := copyBalances(balances)
+		testTransfer(balances, number)
+		if err := history.record(number, Hash{byte(number)}, before, balances); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	heights := []uint64{0, 1}
+	for base := uint64(BalanceSnapshotInterval); base < blocks; base += BalanceSnapshotInterval {
+		heights = append(heights, base-1, base, base+1)
+	}
+	heights = append(heights, blocks-1)
+	for _, height := range heights {
+		hash, got, err := history.at(height)
+		if err != nil {
+			t.Fatalf("height %d: %v", height, err)
+		}
+		if hash != (Hash{byte(height)}) {
+			t.Errorf("height %d: got hash %s", height, hash)
+		}
+		if want := replay(genesis, height); !reflect.DeepEqual(got, want) {
+			t.Errorf("height %d: got balances %v, replay gives %v", height, got, want)
+		}
+	}
+
+	if _, _, err := history.at(blocks); err == nil {
+		t.Errorf("got balances for height %d past the last block", blocks)
+	}
+}
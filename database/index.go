@@ -0,0 +1,97 @@
+package database
+
+import (
+	"fmt"
+)
+
+type TxLocation struct {
+	BlockHash   Hash   `json:"block_hash"`
+	BlockNumber uint64 `json:"block_number"`
+	Index       int    `json:"index"`
+}
+
+type chainIndex struct {
+	blocks   []Hash
+	numbers  map[Hash]uint64
+	txs      map[Hash]TxLocation
+	accounts map[Account][]Hash
+}
+
+func newChainIndex() *chainIndex {
+	return &chainIndex{
+		blocks:   make([]Hash, 0),
+		numbers:  make(map[Hash]uint64),
+		txs:      make(map[Hash]TxLocation),
+		accounts: make(map[Account][]Hash),
+	}
+}
+
+func (c *chainIndex) record(hash Hash, block *Block) error {
+	number := block.Header.Number
+	if number != uint64(len(c.blocks)) {
+		return fmt.Errorf("chain index expected block %d but got %d", len(c.blocks), number)
+	}
+	txHashes := make([]Hash, len(block.Txs))
+	for i, tx := range block.Txs {
+		txHash, err := tx.Hash()
+		if err != nil {
+			return err
+		}
+		txHashes[i] = txHash
+	}
+	c.blocks = append(c.blocks, hash)
+	c.numbers[hash] = number
+	for i, tx := range block.Txs {
+		txHash := txHashes[i]
+		c.txs[txHash] = TxLocation{BlockHash: hash, BlockNumber: number, Index: i}
+		c.accounts[tx.From] = append(c.accounts[tx.From], txHash)
+		if tx.To != tx.From {
+			c.accounts[tx.To] = append(c.accounts[tx.To], txHash)
+		}
+	}
+	return nil
+}
+
+// truncate forgets the blocks from number on.
+func (c *chainIndex) truncate(number uint64) {
+	if number >= uint64(len(c.blocks)) {
+		return
+	}
+	for _, hash := range c.blocks[number:] {
+		delete(c.numbers, hash)
+	}
+	c.blocks = c.blocks[:number]
+	for hash, location := range c.txs {
+		if location.BlockNumber >= number {
+			delete(c.txs, hash)
+		}
+	}
+	for account, hashes := range c.accounts {
+		kept := make([]Hash, 0, len(hashes))
+		for _, hash := range hashes {
+			if _, ok := c.txs[hash]; ok {
+				kept = append(kept, hash)
+			}
+		}
+		if len(kept) == 0 {
+			delete(c.accounts, account)
+			continue
+		}
+		c.accounts[account] = kept
+	}
+}
+
+func (c *chainIndex) hashAt(number uint64) (Hash, bool) {
+	if number >= uint64(len(c.blocks)) {
+		return Hash{}, false
+	}
+	return c.blocks[number], true
+}
+
+// after returns the block store cursor that positions a read at number.
+func (c *chainIndex) after(number uint64) string {
+	if number == 0 {
+		return AfterGenesis
+	}
+	return c.blocks[number-1].String()
+}
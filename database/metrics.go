@@ -0,0 +1,21 @@
+package database
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var BlockStoreReadDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+	Namespace: "yarbit",
+	Subsystem: "block_store",
+	Name:      "read_duration_seconds",
+	Help:      "Time taken to read blocks from the block store.",
+	Buckets:   prometheus.DefBuckets,
+})
+
+var BlockStoreWriteDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+	Namespace: "yarbit",
+	Subsystem: "block_store",
+	Name:      "write_duration_seconds",
+	Help:      "Time taken to write blocks to the block store.",
+	Buckets:   prometheus.DefBuckets,
+})
@@ -1,10 +1,12 @@
 package database
 
 import (
+	"context"
 	"fmt"
 	"math"
 	"reflect"
 
+	"github.com/kparkins/yarbit/logging"
 	"github.com/pkg/errors"
 )
 
@@ -18,6 +20,10 @@ type State struct {
 	lastBlockHash Hash
 	lastBlock     *Block
 	hasGenesis    bool
+	history       *balanceHistory
+	index         *chainIndex
+	logger        logging.Logger
+	genesis       *Genesis
 }
 
 func NewStateFromDisk(dataDir string) *State {
@@ -29,10 +35,21 @@ func NewStateFromDisk(dataDir string) *State {
 		lastBlockHash: Hash{},
 		lastBlock:     NewBlock(Hash{}, 0, 0, make([]Tx, 0)),
 		hasGenesis:    false,
+		history:       newBalanceHistory(),
+		index:         newChainIndex(),
+		logger:        logging.Default().Component("state"),
 	}
 	return state
 }
 
+func (s *State) Genesis() *Genesis {
+	return s.genesis
+}
+
+func (s *State) SetLogger(logger logging.Logger) {
+	s.logger = logger
+}
+
 func (s *State) Balances() map[Account]uint {
 	result := make(map[Account]uint, len(s.balances))
 	for k, v := range s.balances {
@@ -41,6 +58,10 @@ func (s *State) Balances() map[Account]uint {
 	return result
 }
 
+func (s *State) BalancesAt(height uint64) (Hash, map[Account]uint, error) {
+	return s.history.at(height)
+}
+
 func (s *State) Load() error {
 	if err := initDataDir(s.dataDir); err != nil {
 		return err
@@ -49,15 +70,26 @@ func (s *State) Load() error {
 	if err != nil {
 		return errors.Wrap(err, "failed to load genesis file")
 	}
-	s.balances = genesis.Balances
+	s.genesis = genesis
+	s.balances = copyBalances(genesis.Balances)
+	s.history = newBalanceHistory()
+	s.index = newChainIndex()
 	blocks, err := s.blockStore.Read(AfterGenesis, math.MaxUint64)
 	if err != nil {
 		return errors.Wrap(err, "failed to load blocks from block store")
 	}
 	for _, block := range blocks {
+		before := s.Balances()
 		if err := s.ApplyBlock(&block); err != nil {
 			return errors.Wrap(err, "failed to apply block")
 		}
+		hash, err := block.Hash()
+		if err != nil {
+			return err
+		}
+		if err := s.record(hash, &block, before, s.balances); err != nil {
+			return err
+		}
 	}
 	if len(blocks) <= 0 {
 		return nil
@@ -73,8 +105,16 @@ func (s *State) Load() error {
 	return nil
 }
 
-func (s *State) GetBlocksAfter(after string) ([]Block, error) {
-	return s.blockStore.Read(after, math.MaxUint64)
+// GetBlocksAfter returns up to limit blocks following the block with the
+// given hash, or following genesis when after is AfterGenesis.
+func (s *State) GetBlocksAfter(after string, limit uint64) ([]Block, error) {
+	return s.blockStore.Read(after, limit)
+}
+
+// StreamBlocksAfter sends every block following after on blockStream without
+// loading them all into memory, closing it when done.
+func (s *State) StreamBlocksAfter(ctx context.Context, after string, blockStream chan<- Block) {
+	s.blockStore.Stream(ctx, after, blockStream)
 }
 
 func (s *State) NextBlockNumber() uint64 {
@@ -87,21 +127,29 @@ func (s *State) NextBlockNumber() uint64 {
 func (s *State) AddBlock(block *Block) (Hash, error) {
 	var hash Hash
 	if block.Header.Number != s.NextBlockNumber() {
-		return hash, fmt.Errorf("new block doesn't have the correct sequence number")
+		return hash, &OutOfOrderBlockError{Err: fmt.Errorf("new block doesn't have the correct sequence number")}
 	}
 	if !reflect.DeepEqual(block.Header.Parent, s.lastBlockHash) {
-		return hash, fmt.Errorf("new block doesn't have the correct parent hash")
+		return hash, &OutOfOrderBlockError{Err: fmt.Errorf("new block doesn't have the correct parent hash")}
 	}
 	c := s.Clone()
 	if err := c.ApplyBlock(block); err != nil {
-		return hash, errors.Wrap(err, "failed to apply block")
+		return hash, &InvalidBlockError{Err: errors.Wrap(err, "failed to apply block")}
 	}
-	hash, err := s.blockStore.Write(block)
+	hash, err := block.Hash()
 	if err != nil {
+		return hash, err
+	}
+	// Record first, a block in the store that is missing from the history and
+	// index would only show up again after a restart.
+	if err := s.record(hash, block, s.balances, c.balances); err != nil {
+		return hash, err
+	}
+	if _, err := s.blockStore.Write(block); err != nil {
+		s.unrecord(block.Header.Number)
 		return hash, errors.Wrap(err, "could not persist new block to data store")
 	}
-	fmt.Printf("Saved new block to storage: \n")
-	fmt.Printf("\t%s\n", hash.String())
+	s.logger.Info("saved new block to storage", "hash", hash, "height", block.Header.Number)
 	s.hasGenesis = true
 	s.balances = c.balances
 	s.lastBlockHash = hash
@@ -109,6 +157,23 @@ func (s *State) AddBlock(block *Block) (Hash, error) {
 	return hash, nil
 }
 
+func (s *State) record(hash Hash, block *Block, before, after map[Account]uint) error {
+	if err := s.history.record(block.Header.Number, hash, before, after); err != nil {
+		return errors.Wrap(err, "failed to record balance history")
+	}
+	if err := s.index.record(hash, block); err != nil {
+		s.history.truncate(block.Header.Number)
+		return errors.Wrap(err, "failed to index block")
+	}
+	return nil
+}
+
+// unrecord forgets the block at number and every block after it.
+func (s *State) unrecord(number uint64) {
+	s.history.truncate(number)
+	s.index.truncate(number)
+}
+
 func (s *State) Clone() *State {
 	return &State{
 		balances:      s.Balances(),
@@ -117,10 +182,14 @@ func (s *State) Clone() *State {
 		lastBlock:     s.lastBlock.Clone(),
 		lastBlockHash: s.lastBlockHash.Clone(),
 		hasGenesis:    s.hasGenesis,
+		history:       s.history,
+		index:         s.index,
+		logger:        s.logger,
+		genesis:       s.genesis,
 	}
 }
 
-func (s *State)ApplyBlock(block *Block) error {
+func (s *State) ApplyBlock(block *Block) error {
 	for _, tx := range block.Txs {
 		if err := s.ApplyTx(tx); err != nil {
 			return err
@@ -155,3 +224,76 @@ func (s *State) LatestBlock() *Block {
 func (s *State) LatestBlockNumber() uint64 {
 	return s.lastBlock.Header.Number
 }
+
+func (s *State) BlockByNumber(number uint64) (Hash, Block, error) {
+	hash, ok := s.index.hashAt(number)
+	if !ok {
+		return hash, Block{}, fmt.Errorf("no block with number %d", number)
+	}
+	blocks, err := s.BlocksFrom(number, 1)
+	if err != nil {
+		return hash, Block{}, err
+	}
+	if len(blocks) < 1 {
+		return hash, Block{}, fmt.Errorf("block %d missing from block store", number)
+	}
+	return hash, blocks[0], nil
+}
+
+func (s *State) BlocksFrom(number, limit uint64) ([]Block, error) {
+	if _, ok := s.index.hashAt(number); !ok {
+		return []Block{}, nil
+	}
+	return s.blockStore.Read(s.index.after(number), limit)
+}
+
+// BlockHashAt returns the hash of the block with number.
+func (s *State) BlockHashAt(number uint64) (Hash, bool) {
+	return s.index.hashAt(number)
+}
+
+// ReadBlocks reads up to limit blocks following the block with hash after
+// straight from the block store. Unlike BlocksFrom it does not use the index,
+// so callers may read without holding the lock that guards the state.
+func (s *State) ReadBlocks(after string, limit uint64) ([]Block, error) {
+	return s.blockStore.Read(after, limit)
+}
+
+func (s *State) BlockByHash(hash Hash) (Block, error) {
+	number, ok := s.index.numbers[hash]
+	if !ok {
+		return Block{}, fmt.Errorf("no block with hash %s", hash)
+	}
+	_, block, err := s.BlockByNumber(number)
+	return block, err
+}
+
+// HasTx reports whether the tx with hash is included in a block.
+func (s *State) HasTx(hash Hash) bool {
+	_, ok := s.index.txs[hash]
+	return ok
+}
+
+func (s *State) TxByHash(hash Hash) (Tx, TxLocation, error) {
+	location, ok := s.index.txs[hash]
+	if !ok {
+		return Tx{}, location, fmt.Errorf("no tx with hash %s", hash)
+	}
+	_, block, err := s.BlockByNumber(location.BlockNumber)
+	if err != nil {
+		return Tx{}, location, err
+	}
+	return block.Txs[location.Index], location, nil
+}
+
+func (s *State) TxLocation(hash Hash) (TxLocation, bool) {
+	location, ok := s.index.txs[hash]
+	return location, ok
+}
+
+func (s *State) AccountTxHashes(account Account) []Hash {
+	hashes := s.index.accounts[account]
+	result := make([]Hash, len(hashes))
+	copy(result, hashes)
+	return result
+}
@@ -1,10 +1,12 @@
 module github.com/kparkins/yarbit
 
-go 1.15
+go 1.16
 
 require (
 	github.com/gorilla/mux v1.8.0
-	github.com/pkg/errors v0.8.1
+	github.com/pkg/errors v0.9.1
+	github.com/prometheus/client_golang v1.11.0
 	github.com/spf13/cobra v1.1.3
-	google.golang.org/genproto v0.0.0-20191108220845-16a3f7862a1a
+	google.golang.org/grpc v1.36.0
+	google.golang.org/protobuf v1.26.0
 )
@@ -0,0 +1,246 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = map[Level]string{
+	LevelDebug: "debug",
+	LevelInfo:  "info",
+	LevelWarn:  "warn",
+	LevelError: "error",
+}
+
+func (l Level) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("level(%d)", int(l))
+}
+
+func ParseLevel(s string) (Level, error) {
+	for level, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return level, nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("unknown log level '%s'", s)
+}
+
+// ParseComponentLevels parses per-component verbosity overrides written as
+// "component=level,component=level".
+func ParseComponentLevels(s string) (map[string]Level, error) {
+	levels := make(map[string]Level)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid component level '%s'", entry)
+		}
+		level, err := ParseLevel(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		levels[strings.TrimSpace(parts[0])] = level
+	}
+	return levels, nil
+}
+
+type Format string
+
+const (
+	FormatText Format = "text"
+	FormatJson Format = "json"
+)
+
+func ParseFormat(s string) (Format, error) {
+	switch Format(strings.ToLower(s)) {
+	case FormatText:
+		return FormatText, nil
+	case FormatJson:
+		return FormatJson, nil
+	}
+	return FormatText, fmt.Errorf("unknown log format '%s'", s)
+}
+
+// Logger writes leveled messages annotated with key/value pairs.
+type Logger interface {
+	Debug(msg string, keyvals ...interface{})
+	Info(msg string, keyvals ...interface{})
+	Warn(msg string, keyvals ...interface{})
+	Error(msg string, keyvals ...interface{})
+	// With returns a logger that adds keyvals to every message.
+	With(keyvals ...interface{}) Logger
+	// Component returns a logger for the named component, which may have its
+	// own verbosity.
+	Component(name string) Logger
+}
+
+type Options struct {
+	Output          io.Writer
+	Format          Format
+	Level           Level
+	ComponentLevels map[string]Level
+}
+
+type sink struct {
+	lock            *sync.Mutex
+	output          io.Writer
+	format          Format
+	level           Level
+	componentLevels map[string]Level
+}
+
+type logger struct {
+	sink      *sink
+	component string
+	fields    []interface{}
+}
+
+func New(options Options) Logger {
+	if options.Output == nil {
+		options.Output = os.Stderr
+	}
+	if options.Format == "" {
+		options.Format = FormatText
+	}
+	return &logger{
+		sink: &sink{
+			lock:            &sync.Mutex{},
+			output:          options.Output,
+			format:          options.Format,
+			level:           options.Level,
+			componentLevels: options.ComponentLevels,
+		},
+	}
+}
+
+// Default returns a text logger writing info and above to stderr.
+func Default() Logger {
+	return New(Options{Level: LevelInfo})
+}
+
+// Nop returns a logger that discards everything.
+func Nop() Logger {
+	return New(Options{Output: io.Discard, Level: LevelError + 1})
+}
+
+func (l *logger) Debug(msg string, keyvals ...interface{}) {
+	l.log(LevelDebug, msg, keyvals)
+}
+
+func (l *logger) Info(msg string, keyvals ...interface{}) {
+	l.log(LevelInfo, msg, keyvals)
+}
+
+func (l *logger) Warn(msg string, keyvals ...interface{}) {
+	l.log(LevelWarn, msg, keyvals)
+}
+
+func (l *logger) Error(msg string, keyvals ...interface{}) {
+	l.log(LevelError, msg, keyvals)
+}
+
+func (l *logger) With(keyvals ...interface{}) Logger {
+	fields := make([]interface{}, 0, len(l.fields)+len(keyvals))
+	fields = append(fields, l.fields...)
+	fields = append(fields, keyvals...)
+	return &logger{sink: l.sink, component: l.component, fields: fields}
+}
+
+func (l *logger) Component(name string) Logger {
+	return &logger{sink: l.sink, component: name, fields: l.fields}
+}
+
+func (l *logger) enabled(level Level) bool {
+	threshold := l.sink.level
+	if componentLevel, ok := l.sink.componentLevels[l.component]; ok {
+		threshold = componentLevel
+	}
+	return level >= threshold
+}
+
+func (l *logger) log(level Level, msg string, keyvals []interface{}) {
+	if !l.enabled(level) {
+		return
+	}
+	fields := make([]interface{}, 0, len(l.fields)+len(keyvals))
+	fields = append(fields, l.fields...)
+	fields = append(fields, keyvals...)
+	if len(fields)%2 != 0 {
+		fields = append(fields, "(missing)")
+	}
+	var line string
+	if l.sink.format == FormatJson {
+		line = l.formatJson(level, msg, fields)
+	} else {
+		line = l.formatText(level, msg, fields)
+	}
+	l.sink.lock.Lock()
+	defer l.sink.lock.Unlock()
+	io.WriteString(l.sink.output, line)
+}
+
+func (l *logger) formatText(level Level, msg string, fields []interface{}) string {
+	var b strings.Builder
+	b.WriteString(time.Now().Format(time.RFC3339))
+	b.WriteString(" ")
+	b.WriteString(strings.ToUpper(level.String()))
+	if l.component != "" {
+		fmt.Fprintf(&b, " [%s]", l.component)
+	}
+	b.WriteString(" ")
+	b.WriteString(msg)
+	for i := 0; i < len(fields); i += 2 {
+		value := fmt.Sprint(fields[i+1])
+		if strings.ContainsAny(value, " \t\n\"") {
+			value = fmt.Sprintf("%q", value)
+		}
+		fmt.Fprintf(&b, " %v=%s", fields[i], value)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+func (l *logger) formatJson(level Level, msg string, fields []interface{}) string {
+	entry := make(map[string]interface{}, len(fields)/2+4)
+	entry["time"] = time.Now().Format(time.RFC3339Nano)
+	entry["level"] = level.String()
+	entry["msg"] = msg
+	if l.component != "" {
+		entry["component"] = l.component
+	}
+	for i := 0; i < len(fields); i += 2 {
+		value := fields[i+1]
+		switch v := value.(type) {
+		case error:
+			value = v.Error()
+		case fmt.Stringer:
+			value = v.String()
+		}
+		entry[fmt.Sprint(fields[i])] = value
+	}
+	content, err := json.Marshal(entry)
+	if err != nil {
+		content, _ = json.Marshal(map[string]string{"level": "error", "msg": err.Error()})
+	}
+	return string(content) + "\n"
+}
@@ -1,36 +1,91 @@
 package node
 
-import "github.com/kparkins/yarbit/database"
+import (
+	"github.com/kparkins/yarbit/client"
+	"github.com/kparkins/yarbit/database"
+)
 
+// The routes and wire types are defined in the client package so that
+// clients, including this node when it talks to its peers, share them.
 const (
-	ApiRouteAddPeer      = "/node/peer"
-	ApiRouteSync         = "/node/sync"
-	ApiRouteAddTx        = "/tx/add"
-	ApiRouteStatus       = "/node/status"
-	ApiRouteListBalances = "/balances/list"
+	ApiRouteAddPeer      = client.ApiRouteAddPeer
+	ApiRouteSync         = client.ApiRouteSync
+	ApiRouteHeaders      = client.ApiRouteHeaders
+	ApiRouteAddTx        = client.ApiRouteAddTx
+	ApiRouteStatus       = client.ApiRouteStatus
+	ApiRouteListPeers    = client.ApiRouteListPeers
+	ApiRouteNodeInfo     = client.ApiRouteNodeInfo
+	ApiRouteListBalances = client.ApiRouteListBalances
+	ApiRouteGetBalance   = client.ApiRouteGetBalance
+	ApiRouteEvents       = client.ApiRouteEvents
+	ApiRouteListBlocks   = client.ApiRouteListBlocks
+	ApiRouteGetBlock     = client.ApiRouteGetBlock
+	ApiRouteGetTx        = client.ApiRouteGetTx
+	ApiRouteAccountTxs   = client.ApiRouteAccountTxs
+	ApiRouteExplorer     = client.ApiRouteExplorer
+	ApiRouteMetrics      = client.ApiRouteMetrics
+	ApiRouteMiningWork   = client.ApiRouteMiningWork
+	ApiRouteMiningSubmit = client.ApiRouteMiningSubmit
+	ApiRouteDevMine      = client.ApiRouteDevMine
+	ApiRouteDevTime      = client.ApiRouteDevTime
+	ApiRouteInventory    = client.ApiRouteInventory
+	ApiRoutePendingTxs   = client.ApiRoutePendingTxs
+	ApiRouteGetPendingTx = client.ApiRouteGetPendingTx
+	ApiRouteRpc          = client.ApiRouteRpc
 
-	ApiQueryParamAfter = "after"
-)
+	ApiQueryParamAfter   = client.ApiQueryParamAfter
+	ApiQueryParamHeight  = client.ApiQueryParamHeight
+	ApiQueryParamTypes   = client.ApiQueryParamTypes
+	ApiQueryParamAccount = client.ApiQueryParamAccount
+	ApiQueryParamLimit   = client.ApiQueryParamLimit
+	ApiQueryParamStream  = client.ApiQueryParamStream
 
-type StatusResponse struct {
-	Hash       database.Hash       `json:"block_hash"`
-	Number     uint64              `json:"block_number"`
-	KnownPeers map[string]PeerNode `json:"known_peers"`
-	PendingTxs []database.Tx       `json:"pending_txs"`
-}
+	HeaderApiKey = client.HeaderApiKey
 
-type AddPeerResponse struct {
-	Success bool   `json:"success"`
-	Message string `json:"message"`
-}
+	ProtocolHttp  = client.ProtocolHttp
+	ProtocolHttps = client.ProtocolHttps
 
-type SyncResult struct {
-	Blocks []database.Block `json:"blocks"`
-}
+	InventoryBlock = client.InventoryBlock
+	InventoryTx    = client.InventoryTx
+)
+
+type (
+	PeerNode            = client.PeerNode
+	PeerScore           = client.PeerScore
+	PeerInfo            = client.PeerInfo
+	PeersResponse       = client.PeersResponse
+	NodeInfo            = client.NodeInfo
+	HandshakeResponse   = client.HandshakeResponse
+	SyncProgress        = client.SyncProgress
+	StatusResponse      = client.StatusResponse
+	SyncResult          = client.SyncResult
+	HeaderEntry         = client.HeaderEntry
+	HeadersResult       = client.HeadersResult
+	BalancesResponse    = client.BalancesResponse
+	BalanceResponse     = client.BalanceResponse
+	TxAddRequest        = client.TxAddRequest
+	TxAddResponse       = client.TxAddResponse
+	BlockResponse       = client.BlockResponse
+	BlocksResponse      = client.BlocksResponse
+	TxResponse          = client.TxResponse
+	AccountTxsResponse  = client.AccountTxsResponse
+	PendingTxResponse   = client.PendingTxResponse
+	PendingTxsResponse  = client.PendingTxsResponse
+	InventoryType       = client.InventoryType
+	InventoryMessage    = client.InventoryMessage
+	BlockTemplate       = client.BlockTemplate
+	SubmitBlockResponse = client.SubmitBlockResponse
+	ErrorResponse       = client.ErrorResponse
+)
 
-type TxAddRequest struct {
-	From  string `json:"from"`
-	To    string `json:"to"`
-	Value uint   `json:"value"`
-	Data  string `json:"data"`
+func NewBlockResponse(hash database.Hash, block database.Block) (BlockResponse, error) {
+	hashes := make([]database.Hash, 0, len(block.Txs))
+	for _, tx := range block.Txs {
+		txHash, err := tx.Hash()
+		if err != nil {
+			return BlockResponse{}, err
+		}
+		hashes = append(hashes, txHash)
+	}
+	return BlockResponse{Hash: hash, Block: block, TxHashes: hashes}, nil
 }
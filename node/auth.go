@@ -0,0 +1,177 @@
+package node
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+type Role string
+
+const (
+	// RolePublic may read chain data. Requests without a key get this role.
+	RolePublic Role = "public"
+	// RoleSubmit may also submit transactions.
+	RoleSubmit Role = "submit"
+	// RolePeer may call the routes nodes use to sync and gossip.
+	RolePeer Role = "peer"
+	// RoleAdmin may call every route.
+	RoleAdmin Role = "admin"
+)
+
+func ParseRole(value string) (Role, error) {
+	switch role := Role(value); role {
+	case RolePublic, RoleSubmit, RolePeer, RoleAdmin:
+		return role, nil
+	}
+	return "", fmt.Errorf("unknown role '%s', expected public, submit, peer or admin", value)
+}
+
+// Allows reports whether a caller with role r may use a route requiring
+// required.
+func (r Role) Allows(required Role) bool {
+	return r == RoleAdmin || r == required || required == RolePublic
+}
+
+// ApiKey grants its role to requests presenting Key as a bearer token or in
+// the X-Api-Key header.
+type ApiKey struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+	Role Role   `json:"role"`
+}
+
+// routeRoles is the role each route requires once api keys are configured.
+// Routes missing from the table are admin only.
+var routeRoles = map[string]Role{
+	ApiRouteStatus:       RolePublic,
+	ApiRouteListPeers:    RolePublic,
+	ApiRouteNodeInfo:     RolePublic,
+	ApiRouteListBalances: RolePublic,
+	ApiRouteGetBalance:   RolePublic,
+	ApiRouteEvents:       RolePublic,
+	ApiRouteListBlocks:   RolePublic,
+	ApiRouteGetBlock:     RolePublic,
+	ApiRouteGetTx:        RolePublic,
+	ApiRoutePendingTxs:   RolePublic,
+	ApiRouteGetPendingTx: RolePublic,
+	ApiRouteAccountTxs:   RolePublic,
+	ApiRouteExplorer:     RolePublic,
+	"/explorer":          RolePublic,
+	ApiRouteMetrics:      RolePublic,
+	ApiRouteRpc:          RolePublic,
+	ApiRouteAddTx:        RoleSubmit,
+	ApiRouteAddPeer:      RolePeer,
+	ApiRouteSync:         RolePeer,
+	ApiRouteHeaders:      RolePeer,
+	ApiRouteInventory:    RolePeer,
+}
+
+type roleContextKey struct{}
+
+// requestRole is the role the auth middleware granted the request.
+func requestRole(ctx context.Context) Role {
+	if role, ok := ctx.Value(roleContextKey{}).(Role); ok {
+		return role
+	}
+	return RoleAdmin
+}
+
+// authEnabled reports whether api keys are configured. Without any, every
+// route is open as before.
+func (n *Node) authEnabled() bool {
+	return len(n.config.ApiKeys) > 0
+}
+
+// authenticate returns the role for token, or false if it matches no key.
+func (n *Node) authenticate(token string) (ApiKey, bool) {
+	for _, key := range n.config.ApiKeys {
+		if subtle.ConstantTimeCompare([]byte(key.Key), []byte(token)) == 1 {
+			return key, true
+		}
+	}
+	return ApiKey{}, false
+}
+
+func requestToken(request *http.Request) string {
+	if key := request.Header.Get(HeaderApiKey); key != "" {
+		return key
+	}
+	authorization := request.Header.Get("Authorization")
+	if strings.HasPrefix(authorization, "Bearer ") {
+		return strings.TrimPrefix(authorization, "Bearer ")
+	}
+	return ""
+}
+
+// authorizeRoutes rejects requests whose key does not grant the role their
+// route requires.
+func (n *Node) authorizeRoutes(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if !n.authEnabled() {
+			next.ServeHTTP(writer, request)
+			return
+		}
+		role := RolePublic
+		if token := requestToken(request); token != "" {
+			key, ok := n.authenticate(token)
+			if !ok {
+				writeJsonErrorResponse(writer, fmt.Errorf("invalid api key"), http.StatusUnauthorized)
+				return
+			}
+			role = key.Role
+		}
+		required := RoleAdmin
+		if current := mux.CurrentRoute(request); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				if routeRole, ok := routeRoles[template]; ok {
+					required = routeRole
+				}
+			}
+		}
+		if !role.Allows(required) {
+			status := http.StatusForbidden
+			if role == RolePublic {
+				status = http.StatusUnauthorized
+			}
+			writeJsonErrorResponse(writer, fmt.Errorf("route requires the %s role", required), status)
+			return
+		}
+		ctx := context.WithValue(request.Context(), roleContextKey{}, role)
+		next.ServeHTTP(writer, request.WithContext(ctx))
+	})
+}
+
+// peerAuthTransport adds the node's peer token to requests it makes to
+// trusted peers. Addresses taken from requests, such as the sender of an
+// inventory or a handshake that is still being verified, are not sent it.
+type peerAuthTransport struct {
+	base    http.RoundTripper
+	token   string
+	trusted func(address string) bool
+}
+
+func (t *peerAuthTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if !t.trusted(request.URL.Host) {
+		return t.base.RoundTrip(request)
+	}
+	request = request.Clone(request.Context())
+	request.Header.Set("Authorization", "Bearer "+t.token)
+	return t.base.RoundTrip(request)
+}
+
+func (n *Node) isTrustedPeer(address string) bool {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	return n.trustedPeers[address]
+}
+
+func (n *Node) trustPeer(address string) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.trustedPeers[address] = true
+}
@@ -0,0 +1,261 @@
+package node
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/kparkins/yarbit/database"
+	"github.com/pkg/errors"
+)
+
+const DefaultBlocksLimit = 10
+const MaxBlocksLimit = 100
+
+const DefaultAccountTxsLimit = 50
+const MaxAccountTxsLimit = 500
+
+func (n *Node) handleListBlocks() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		limit, err := parseLimit(request, DefaultBlocksLimit, MaxBlocksLimit)
+		if err != nil {
+			writeJsonErrorResponse(writer, err, http.StatusBadRequest)
+			return
+		}
+		blocks, err := n.LatestBlocks(limit)
+		if err != nil {
+			writeJsonErrorResponse(writer, err, http.StatusInternalServerError)
+			return
+		}
+		writeJsonResponse(writer, BlocksResponse{Blocks: blocks})
+	}
+}
+
+func (n *Node) handleGetBlock() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		id := mux.Vars(request)["id"]
+		var hash database.Hash
+		var block database.Block
+		var err error
+		if number, parseErr := strconv.ParseUint(id, 10, 64); parseErr == nil {
+			hash, block, err = n.BlockByNumber(number)
+		} else if hash, err = database.ParseHash(id); err == nil {
+			block, err = n.BlockByHash(hash)
+		}
+		if err != nil {
+			writeJsonErrorResponse(writer, err, http.StatusNotFound)
+			return
+		}
+		response, err := NewBlockResponse(hash, block)
+		if err != nil {
+			writeJsonErrorResponse(writer, err, http.StatusInternalServerError)
+			return
+		}
+		writeJsonResponse(writer, response)
+	}
+}
+
+func (n *Node) handleGetTx() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		hash, err := database.ParseHash(mux.Vars(request)["hash"])
+		if err != nil {
+			writeJsonErrorResponse(writer, err, http.StatusBadRequest)
+			return
+		}
+		tx, location, err := n.TxByHash(hash)
+		if err != nil {
+			writeJsonErrorResponse(writer, err, http.StatusNotFound)
+			return
+		}
+		writeJsonResponse(writer, TxResponse{Hash: hash, Location: location, Tx: tx})
+	}
+}
+
+func (n *Node) handleAccountTxs() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		account := database.NewAccount(mux.Vars(request)["account"])
+		limit, err := parseLimit(request, DefaultAccountTxsLimit, MaxAccountTxsLimit)
+		if err != nil {
+			writeJsonErrorResponse(writer, err, http.StatusBadRequest)
+			return
+		}
+		var after database.Hash
+		if value := request.URL.Query().Get(ApiQueryParamAfter); value != "" {
+			if after, err = database.ParseHash(value); err != nil {
+				writeJsonErrorResponse(writer, errors.Wrap(err, "invalid after"), http.StatusBadRequest)
+				return
+			}
+		}
+		txs, next, err := n.AccountTxs(account, after, limit)
+		if errors.Is(err, errUnknownCursor) {
+			writeJsonErrorResponse(writer, err, http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			writeJsonErrorResponse(writer, err, http.StatusInternalServerError)
+			return
+		}
+		response := AccountTxsResponse{Account: account, Txs: txs}
+		if !next.IsEmpty() {
+			response.Next = next.String()
+		}
+		writeJsonResponse(writer, response)
+	}
+}
+
+func parseLimit(request *http.Request, defaultLimit, maxLimit uint64) (uint64, error) {
+	value := request.URL.Query().Get(ApiQueryParamLimit)
+	if value == "" {
+		return defaultLimit, nil
+	}
+	limit, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, errors.Wrap(err, "invalid limit")
+	}
+	if limit > maxLimit {
+		return maxLimit, nil
+	}
+	return limit, nil
+}
+
+// LatestBlocks returns up to limit blocks ending at the tip, newest first.
+func (n *Node) LatestBlocks(limit uint64) ([]BlockResponse, error) {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	result := make([]BlockResponse, 0, limit)
+	if n.state.LatestBlockHash().IsEmpty() || limit == 0 {
+		return result, nil
+	}
+	latest := n.state.LatestBlockNumber()
+	first := uint64(0)
+	if latest+1 > limit {
+		first = latest + 1 - limit
+	}
+	blocks, err := n.state.BlocksFrom(first, latest-first+1)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(blocks) - 1; i >= 0; i-- {
+		hash, err := blocks[i].Hash()
+		if err != nil {
+			return nil, err
+		}
+		response, err := NewBlockResponse(hash, blocks[i])
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, response)
+	}
+	return result, nil
+}
+
+func (n *Node) BlockByNumber(number uint64) (database.Hash, database.Block, error) {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	return n.state.BlockByNumber(number)
+}
+
+func (n *Node) BlockByHash(hash database.Hash) (database.Block, error) {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	return n.state.BlockByHash(hash)
+}
+
+func (n *Node) TxByHash(hash database.Hash) (database.Tx, database.TxLocation, error) {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	return n.state.TxByHash(hash)
+}
+
+// errUnknownCursor is returned for an after cursor that is not a tx of the
+// account.
+var errUnknownCursor = errors.New("after is not a tx of the account")
+
+// AccountTxs returns up to limit txs of account, oldest first, that follow the
+// tx with hash after, or from the first one when after is empty. It also
+// returns the cursor for the next page, which is empty on the last one. The
+// blocks holding the txs are read without holding the node lock.
+func (n *Node) AccountTxs(account database.Account, after database.Hash, limit uint64) ([]TxResponse, database.Hash, error) {
+	n.lock.RLock()
+	hashes := n.state.AccountTxHashes(account)
+	start := 0
+	if !after.IsEmpty() {
+		start = -1
+		for i, hash := range hashes {
+			if hash == after {
+				start = i + 1
+				break
+			}
+		}
+		if start < 0 {
+			n.lock.RUnlock()
+			return nil, database.Hash{}, errUnknownCursor
+		}
+	}
+	end := len(hashes)
+	if uint64(end-start) > limit {
+		end = start + int(limit)
+	}
+	page := hashes[start:end]
+	locations := make([]database.TxLocation, len(page))
+	for i, hash := range page {
+		locations[i], _ = n.state.TxLocation(hash)
+	}
+	// Txs are indexed in chain order, so the page covers runs of
+	// consecutive blocks that are each read with one scan of the store.
+	runs := make([]blockRun, 0)
+	for _, location := range locations {
+		number := location.BlockNumber
+		if len(runs) > 0 {
+			last := &runs[len(runs)-1]
+			if number < last.first+last.count {
+				continue
+			}
+			if number == last.first+last.count {
+				last.count++
+				continue
+			}
+		}
+		run := blockRun{first: number, count: 1, after: database.AfterGenesis}
+		if number > 0 {
+			previous, _ := n.state.BlockHashAt(number - 1)
+			run.after = previous.String()
+		}
+		runs = append(runs, run)
+	}
+	n.lock.RUnlock()
+
+	blocks := make(map[uint64]database.Block)
+	for _, run := range runs {
+		read, err := n.state.ReadBlocks(run.after, run.count)
+		if err != nil {
+			return nil, database.Hash{}, errors.Wrap(err, fmt.Sprintf("failed to read blocks from %d", run.first))
+		}
+		for _, block := range read {
+			blocks[block.Header.Number] = block
+		}
+	}
+	txs := make([]TxResponse, 0, len(page))
+	for i, hash := range page {
+		location := locations[i]
+		block, ok := blocks[location.BlockNumber]
+		if !ok || location.Index >= len(block.Txs) {
+			return nil, database.Hash{}, fmt.Errorf("failed to load tx %s from block %d", hash, location.BlockNumber)
+		}
+		txs = append(txs, TxResponse{Hash: hash, Location: location, Tx: block.Txs[location.Index]})
+	}
+	var next database.Hash
+	if end < len(hashes) && len(page) > 0 {
+		next = page[len(page)-1]
+	}
+	return txs, next, nil
+}
+
+// blockRun is count consecutive blocks starting at first, read from the
+// block store after the cursor after.
+type blockRun struct {
+	first uint64
+	count uint64
+	after string
+}
@@ -1,12 +1,45 @@
 package node
 
-import "github.com/kparkins/yarbit/database"
+import (
+	"time"
+
+	"github.com/kparkins/yarbit/database"
+	"github.com/kparkins/yarbit/logging"
+)
+
+const DefaultSyncInterval = 10 * time.Second
 
 type Config struct {
-	DataDir      string
-	IpAddress    string
-	Port         uint64
-	Protocol     string
-	Bootstrap    PeerNode
-	MinerAccount database.Account
+	Version   string
+	DataDir   string
+	IpAddress string
+	Port      uint64
+	GrpcPort  uint64
+	Protocol  string
+	// TlsCertFile and TlsKeyFile serve the API over https. TlsCaFile is
+	// trusted when connecting to peers and, with MutualTls, is required to
+	// have signed the certificates peers present.
+	TlsCertFile string
+	TlsKeyFile  string
+	TlsCaFile   string
+	MutualTls   bool
+	// ApiKeys restrict routes to the roles of the keys presented. PeerToken
+	// is sent to peers, which must know it as a key with the peer role.
+	ApiKeys   []ApiKey
+	PeerToken string
+	// MaxBodyBytes caps request bodies, DefaultMaxBodyBytes when zero and
+	// unlimited when negative. RateLimits default to DefaultRateLimits.
+	MaxBodyBytes int64
+	RateLimits   RateLimits
+	// Mempool bounds the pending txs, see MempoolLimits for the defaults.
+	Mempool       MempoolLimits
+	Bootstraps    []PeerNode
+	MinerAccount  database.Account
+	MiningEnabled bool
+	MiningWorkers int
+	SignerKeyFile string
+	// Dev enables instant sealing and the /dev routes.
+	Dev          bool
+	SyncInterval time.Duration
+	Logger       logging.Logger
 }
@@ -0,0 +1,67 @@
+package node
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+type DevTimeRequest struct {
+	Seconds int64 `json:"seconds"`
+}
+
+type DevTimeResponse struct {
+	Time uint64 `json:"time"`
+}
+
+func (n *Node) handleDevMine() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		block, err := n.MineBlock(request.Context())
+		if err != nil {
+			writeJsonErrorResponse(writer, err, submissionStatus(err))
+			return
+		}
+		hash, err := block.Hash()
+		if err != nil {
+			writeJsonErrorResponse(writer, err, http.StatusInternalServerError)
+			return
+		}
+		response, err := NewBlockResponse(hash, *block)
+		if err != nil {
+			writeJsonErrorResponse(writer, err, http.StatusInternalServerError)
+			return
+		}
+		writeJsonResponse(writer, response)
+	}
+}
+
+func (n *Node) handleDevTime() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		var timeRequest DevTimeRequest
+		if err := readJsonRequest(request, &timeRequest); err != nil {
+			writeJsonErrorResponse(writer, err, http.StatusBadRequest)
+			return
+		}
+		defer request.Body.Close()
+		n.AdvanceTime(time.Duration(timeRequest.Seconds) * time.Second)
+		writeJsonResponse(writer, DevTimeResponse{Time: uint64(n.now().Unix())})
+	}
+}
+
+// AdvanceTime moves the clock used for new block timestamps forward by d.
+func (n *Node) AdvanceTime(d time.Duration) {
+	atomic.AddInt64(&n.timeOffset, int64(d))
+}
+
+func (n *Node) now() time.Time {
+	return time.Now().Add(time.Duration(atomic.LoadInt64(&n.timeOffset)))
+}
+
+// triggerMining asks the foreman to start sealing right away instead of
+// waiting for its next tick.
+func (n *Node) triggerMining() {
+	select {
+	case n.mineNow <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,68 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/kparkins/yarbit/database"
+	"github.com/pkg/errors"
+)
+
+func (n *Node) handleEvents() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		flusher, ok := writer.(http.Flusher)
+		if !ok {
+			writeJsonErrorResponse(writer, errors.New("streaming unsupported"), http.StatusInternalServerError)
+			return
+		}
+		events, unsubscribe := n.events.Subscribe(parseEventFilter(request))
+		defer unsubscribe()
+
+		writer.Header().Set("Content-Type", "text/event-stream")
+		writer.Header().Set("Cache-Control", "no-cache")
+		writer.Header().Set("Connection", "keep-alive")
+		writer.WriteHeader(http.StatusOK)
+		flusher.Flush()
+		for {
+			select {
+			case <-request.Context().Done():
+				return
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				content, err := json.Marshal(event)
+				if err != nil {
+					continue
+				}
+				fmt.Fprintf(writer, "event: %s\ndata: %s\n\n", event.Type, content)
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+func parseEventFilter(request *http.Request) EventFilter {
+	filter := EventFilter{
+		Types:    make(map[EventType]bool),
+		Accounts: make(map[database.Account]bool),
+	}
+	query := request.URL.Query()
+	for _, value := range query[ApiQueryParamTypes] {
+		for _, t := range strings.Split(value, ",") {
+			if t != "" {
+				filter.Types[EventType(t)] = true
+			}
+		}
+	}
+	for _, value := range query[ApiQueryParamAccount] {
+		for _, account := range strings.Split(value, ",") {
+			if account != "" {
+				filter.Accounts[database.NewAccount(account)] = true
+			}
+		}
+	}
+	return filter
+}
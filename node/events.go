@@ -0,0 +1,150 @@
+package node
+
+import (
+	"sync"
+
+	"github.com/kparkins/yarbit/database"
+)
+
+type EventType string
+
+const (
+	EventNewBlock    EventType = "new_block"
+	EventPendingTx   EventType = "pending_tx"
+	EventDroppedTx   EventType = "dropped_tx"
+	EventPeerAdded   EventType = "peer_added"
+	EventPeerRemoved EventType = "peer_removed"
+	// EventPeerBanned peers stay known but are not synced or gossiped with
+	// until their ban ends.
+	EventPeerBanned EventType = "peer_banned"
+)
+
+const eventBufferSize = 64
+
+type Event struct {
+	Type     EventType          `json:"type"`
+	Data     interface{}        `json:"data"`
+	Accounts []database.Account `json:"-"`
+}
+
+type NewBlockEvent struct {
+	Hash  database.Hash  `json:"block_hash"`
+	Block database.Block `json:"block"`
+}
+
+type PendingTxEvent struct {
+	Hash database.Hash `json:"tx_hash"`
+	Tx   database.Tx   `json:"tx"`
+}
+
+type PeerEvent struct {
+	Peer PeerNode `json:"peer"`
+}
+
+type EventFilter struct {
+	Types    map[EventType]bool
+	Accounts map[database.Account]bool
+}
+
+func (f EventFilter) Match(event Event) bool {
+	if len(f.Types) > 0 && !f.Types[event.Type] {
+		return false
+	}
+	if len(f.Accounts) == 0 {
+		return true
+	}
+	for _, account := range event.Accounts {
+		if f.Accounts[account] {
+			return true
+		}
+	}
+	return false
+}
+
+type subscription struct {
+	filter EventFilter
+	events chan Event
+}
+
+type EventBus struct {
+	lock          *sync.RWMutex
+	nextId        uint64
+	subscriptions map[uint64]subscription
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{
+		lock:          &sync.RWMutex{},
+		subscriptions: make(map[uint64]subscription),
+	}
+}
+
+// Subscribe registers a subscriber for the events matching filter. The returned
+// function must be called to release the subscription.
+func (b *EventBus) Subscribe(filter EventFilter) (<-chan Event, func()) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	id := b.nextId
+	b.nextId++
+	events := make(chan Event, eventBufferSize)
+	b.subscriptions[id] = subscription{filter: filter, events: events}
+	return events, func() {
+		b.lock.Lock()
+		defer b.lock.Unlock()
+		if _, ok := b.subscriptions[id]; ok {
+			delete(b.subscriptions, id)
+			close(events)
+		}
+	}
+}
+
+// Publish delivers event to every matching subscriber without blocking. Slow
+// subscribers whose buffers are full miss the event.
+func (b *EventBus) Publish(event Event) {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	for _, s := range b.subscriptions {
+		if !s.filter.Match(event) {
+			continue
+		}
+		select {
+		case s.events <- event:
+		default:
+		}
+	}
+}
+
+func newBlockEvent(hash database.Hash, block database.Block) Event {
+	accounts := []database.Account{block.Header.Miner}
+	for _, tx := range block.Txs {
+		accounts = append(accounts, tx.From, tx.To)
+	}
+	return Event{
+		Type:     EventNewBlock,
+		Data:     NewBlockEvent{Hash: hash, Block: block},
+		Accounts: accounts,
+	}
+}
+
+func newPendingTxEvent(hash database.Hash, tx database.Tx) Event {
+	return Event{
+		Type:     EventPendingTx,
+		Data:     PendingTxEvent{Hash: hash, Tx: tx},
+		Accounts: []database.Account{tx.From, tx.To},
+	}
+}
+
+func newDroppedTxEvent(dropped DroppedTx) Event {
+	return Event{
+		Type:     EventDroppedTx,
+		Data:     dropped,
+		Accounts: []database.Account{dropped.Tx.From, dropped.Tx.To},
+	}
+}
+
+func newPeerEvent(eventType EventType, peer PeerNode) Event {
+	return Event{
+		Type: eventType,
+		Data: PeerEvent{Peer: peer},
+	}
+}
@@ -0,0 +1,18 @@
+package node
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed explorer
+var explorerFiles embed.FS
+
+func explorerHandler() http.Handler {
+	root, err := fs.Sub(explorerFiles, "explorer")
+	if err != nil {
+		panic(err)
+	}
+	return http.StripPrefix(ApiRouteExplorer, http.FileServer(http.FS(root)))
+}
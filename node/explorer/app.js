@@ -0,0 +1,220 @@
+(function () {
+  "use strict";
+
+  var content = document.getElementById("content");
+
+  function get(path) {
+    return fetch(path).then(function (response) {
+      return response.json().then(function (body) {
+        if (!response.ok) {
+          throw new Error(body.error || response.statusText);
+        }
+        return body;
+      });
+    });
+  }
+
+  function escape(value) {
+    return String(value).replace(/[&<>"']/g, function (c) {
+      return {"&": "&amp;", "<": "&lt;", ">": "&gt;", "\"": "&quot;", "'": "&#39;"}[c];
+    });
+  }
+
+  function short(hash) {
+    return hash.substring(0, 16) + "…";
+  }
+
+  function link(route, text, css) {
+    return "<a class=\"" + (css || "") + "\" href=\"#/" + route + "\">" + escape(text) + "</a>";
+  }
+
+  function blockLink(hash) {
+    return link("block/" + hash, short(hash), "hash");
+  }
+
+  function txLink(hash) {
+    return link("tx/" + hash, short(hash), "hash");
+  }
+
+  function accountLink(account) {
+    return link("account/" + encodeURIComponent(account), account || "(none)");
+  }
+
+  function time(seconds) {
+    return new Date(seconds * 1000).toLocaleString();
+  }
+
+  function table(headers, rows) {
+    var html = "<table><tr>";
+    headers.forEach(function (h) {
+      html += "<th>" + h + "</th>";
+    });
+    html += "</tr>";
+    rows.forEach(function (row) {
+      html += "<tr><td>" + row.join("</td><td>") + "</td></tr>";
+    });
+    return html + "</table>";
+  }
+
+  function section(title, body) {
+    return "<section><h2>" + title + "</h2>" + body + "</section>";
+  }
+
+  function txRows(txs, hashes) {
+    return txs.map(function (tx, i) {
+      return [
+        hashes ? txLink(hashes[i]) : "",
+        accountLink(tx.from),
+        accountLink(tx.to),
+        escape(tx.value),
+        escape(tx.data),
+        time(tx.time)
+      ];
+    });
+  }
+
+  // Pending txs have no page of their own until they are mined.
+  function pendingTxRows(pending) {
+    return pending.map(function (p) {
+      var row = txRows([p.tx])[0];
+      row[0] = "<span class=\"hash\" title=\"" + escape(p.tx_hash) + "\">" + escape(short(p.tx_hash)) + "</span>";
+      return row;
+    });
+  }
+
+  function showError(err) {
+    content.innerHTML = section("Error", "<p class=\"error\">" + escape(err.message) + "</p>");
+  }
+
+  function dashboard() {
+    return Promise.all([get("/blocks?limit=20"), get("/node/status"), get("/tx/pending")]).then(function (results) {
+      var blocks = results[0].blocks;
+      var status = results[1];
+      var pending = results[2].txs;
+      var blockRows = blocks.map(function (b) {
+        return [
+          link("block/" + b.block.header.number, b.block.header.number),
+          blockLink(b.block_hash),
+          accountLink(b.block.header.miner),
+          escape(b.block.payload.length),
+          time(b.block.header.time)
+        ];
+      });
+      var scores = status.peer_scores || {};
+      var peerRows = Object.keys(status.known_peers).map(function (address) {
+        var peer = status.known_peers[address];
+        var score = scores[address] ? scores[address].score : 0;
+        return [escape(address), peer.is_bootstrap ? "yes" : "no", peer.is_active ? "yes" : "no", escape(score)];
+      });
+      content.innerHTML =
+        section("Latest blocks", table(["Number", "Hash", "Miner", "Txs", "Time"], blockRows)) +
+        section("Mempool (" + pending.length + ")",
+          table(["Hash", "From", "To", "Value", "Data", "Time"], pendingTxRows(pending))) +
+        section("Peers", table(["Address", "Bootstrap", "Active", "Score"], peerRows));
+    });
+  }
+
+  function block(id) {
+    return get("/blocks/" + encodeURIComponent(id)).then(function (b) {
+      var header = b.block.header;
+      var txs = b.block.payload;
+      var hashes = b.tx_hashes;
+      var rows = [
+        ["Hash", "<span class=\"hash\">" + escape(b.block_hash) + "</span>"],
+        ["Number", escape(header.number)],
+        ["Parent", blockLink(header.parent)],
+        ["Miner", accountLink(header.miner)],
+        ["Nonce", escape(header.nonce)],
+        ["Time", time(header.time)]
+      ];
+      content.innerHTML =
+        section("Block " + escape(header.number), table(["Field", "Value"], rows)) +
+        section("Transactions", table(["Hash", "From", "To", "Value", "Data", "Time"], txRows(txs, hashes)));
+    });
+  }
+
+  function tx(hash) {
+    return get("/tx/" + encodeURIComponent(hash)).then(function (t) {
+      var rows = [
+        ["Hash", "<span class=\"hash\">" + escape(t.tx_hash) + "</span>"],
+        ["Block", blockLink(t.location.block_hash) + " (#" + escape(t.location.block_number) + ")"],
+        ["From", accountLink(t.tx.from)],
+        ["To", accountLink(t.tx.to)],
+        ["Value", escape(t.tx.value)],
+        ["Data", escape(t.tx.data)],
+        ["Time", time(t.tx.time)]
+      ];
+      content.innerHTML = section("Transaction", table(["Field", "Value"], rows));
+    });
+  }
+
+  function account(name) {
+    var path = encodeURIComponent(name);
+    return Promise.all([get("/balances/" + path), get("/accounts/" + path + "/txs")]).then(function (results) {
+      var balance = results[0];
+      var txs = results[1].txs;
+      var rows = txs.map(function (t) {
+        return [
+          txLink(t.tx_hash),
+          link("block/" + t.location.block_number, t.location.block_number),
+          accountLink(t.tx.from),
+          accountLink(t.tx.to),
+          escape(t.tx.value),
+          time(t.tx.time)
+        ];
+      });
+      content.innerHTML =
+        section("Account " + escape(name), "<p>Balance: <strong>" + escape(balance.balance) + "</strong></p>") +
+        section("History", table(["Hash", "Block", "From", "To", "Value", "Time"], rows));
+    });
+  }
+
+  function route() {
+    var parts = location.hash.replace(/^#\/?/, "").split("/");
+    var page;
+    switch (parts[0]) {
+      case "block":
+        page = block(parts[1]);
+        break;
+      case "tx":
+        page = tx(parts[1]);
+        break;
+      case "account":
+        page = account(decodeURIComponent(parts[1] || ""));
+        break;
+      default:
+        page = dashboard();
+    }
+    page.catch(showError);
+  }
+
+  document.getElementById("search").addEventListener("submit", function (e) {
+    e.preventDefault();
+    var query = document.getElementById("query").value.trim();
+    if (/^[0-9]+$/.test(query)) {
+      location.hash = "#/block/" + query;
+    } else if (/^[0-9a-f]{64}$/i.test(query)) {
+      get("/tx/" + query).then(function () {
+        location.hash = "#/tx/" + query;
+      }, function () {
+        location.hash = "#/block/" + query;
+      });
+    } else if (query) {
+      location.hash = "#/account/" + encodeURIComponent(query);
+    }
+  });
+
+  if (window.EventSource) {
+    var events = new EventSource("/events?types=new_block,pending_tx,peer_added,peer_removed,peer_banned");
+    ["new_block", "pending_tx", "peer_added", "peer_removed", "peer_banned"].forEach(function (type) {
+      events.addEventListener(type, function () {
+        if (location.hash === "" || location.hash === "#/") {
+          route();
+        }
+      });
+    });
+  }
+
+  window.addEventListener("hashchange", route);
+  route();
+})();
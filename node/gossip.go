@@ -0,0 +1,304 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/kparkins/yarbit/client"
+	"github.com/kparkins/yarbit/database"
+	"github.com/kparkins/yarbit/logging"
+	"github.com/pkg/errors"
+)
+
+const gossipTimeout = 4 * time.Second
+const seenTTL = 10 * time.Minute
+
+// maxGossipFetches caps the announced objects fetched at the same time.
+// Announcements beyond it are dropped and left to the next announcer.
+const maxGossipFetches = 16
+
+// seenCache remembers recently announced or received hashes so each object
+// is only fetched and relayed once. Hashes being fetched are tracked apart
+// from the seen ones, so a failed fetch can be retried from another peer.
+type seenCache struct {
+	lock     *sync.Mutex
+	entries  map[database.Hash]time.Time
+	inFlight map[database.Hash]struct{}
+	swept    time.Time
+}
+
+func newSeenCache() *seenCache {
+	return &seenCache{
+		lock:     &sync.Mutex{},
+		entries:  make(map[database.Hash]time.Time),
+		inFlight: make(map[database.Hash]struct{}),
+		swept:    time.Now(),
+	}
+}
+
+// add records hash as seen.
+func (c *seenCache) add(hash database.Hash) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	now := time.Now()
+	c.sweep(now)
+	c.entries[hash] = now
+}
+
+// claim reports whether hash is neither seen nor being fetched, marking it
+// as being fetched if so. Every successful claim must be released.
+func (c *seenCache) claim(hash database.Hash) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	now := time.Now()
+	c.sweep(now)
+	if seen, ok := c.entries[hash]; ok && now.Sub(seen) < seenTTL {
+		return false
+	}
+	if _, ok := c.inFlight[hash]; ok {
+		return false
+	}
+	c.inFlight[hash] = struct{}{}
+	return true
+}
+
+// release ends the fetch of hash, recording it as seen if it succeeded.
+func (c *seenCache) release(hash database.Hash, seen bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.inFlight, hash)
+	if seen {
+		c.entries[hash] = time.Now()
+	}
+}
+
+// sweep forgets expired hashes at most once per seenTTL.
+func (c *seenCache) sweep(now time.Time) {
+	if now.Sub(c.swept) < seenTTL {
+		return
+	}
+	c.swept = now
+	for hash, seen := range c.entries {
+		if now.Sub(seen) >= seenTTL {
+			delete(c.entries, hash)
+		}
+	}
+}
+
+type gossip struct {
+	node    *Node
+	client  *http.Client
+	seen    *seenCache
+	fetches chan struct{}
+	logger  logging.Logger
+}
+
+func newGossip(n *Node) *gossip {
+	return &gossip{
+		node:    n,
+		client:  n.newHttpClient(gossipTimeout),
+		seen:    newSeenCache(),
+		fetches: make(chan struct{}, maxGossipFetches),
+		logger:  n.config.Logger.Component("gossip"),
+	}
+}
+
+// announce sends the inventory to every known peer except the one it came
+// from. It does not block the caller.
+func (g *gossip) announce(inventoryType InventoryType, hash database.Hash, origin string) {
+	g.seen.add(hash)
+	message := InventoryMessage{
+		Type:   inventoryType,
+		Hashes: []database.Hash{hash},
+		From:   g.node.self(),
+	}
+	for address, peer := range g.node.Peers() {
+		if address == origin {
+			continue
+		}
+		go func(peer PeerNode) {
+			ctx, cancel := context.WithTimeout(context.Background(), gossipTimeout)
+			defer cancel()
+			if err := peerClient(g.client, peer).SendInventory(ctx, message); err != nil {
+				g.logger.Debug("error announcing inventory", "peer", peer.SocketAddress(), "type", inventoryType, "hash", hash, "error", err)
+			}
+		}(peer)
+	}
+}
+
+// receive fetches the announced hashes that are new from peer, the known peer
+// that sent the announcement.
+func (g *gossip) receive(inventoryType InventoryType, hashes []database.Hash, peer PeerNode) {
+	for _, hash := range hashes {
+		if !g.seen.claim(hash) {
+			continue
+		}
+		select {
+		case g.fetches <- struct{}{}:
+		default:
+			g.seen.release(hash, false)
+			g.logger.Debug("too many fetches, dropping announcement", "peer", peer.SocketAddress(), "hash", hash)
+			continue
+		}
+		go func(hash database.Hash) {
+			defer func() { <-g.fetches }()
+			g.seen.release(hash, g.fetch(inventoryType, hash, peer))
+		}(hash)
+	}
+}
+
+// fetch downloads the announced object from peer unless it is already known.
+// It reports whether the object is known afterwards. A peer that no longer
+// has the object is not penalized, since it may have been mined or dropped
+// since the announcement.
+func (g *gossip) fetch(inventoryType InventoryType, hash database.Hash, peer PeerNode) bool {
+	origin := peer.SocketAddress()
+	if g.node.IsPeerBanned(origin) {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), gossipTimeout)
+	defer cancel()
+	switch inventoryType {
+	case InventoryBlock:
+		if _, err := g.node.BlockByHash(hash); err == nil {
+			return true
+		}
+		block, err := fetchBlock(ctx, g.client, peer, hash)
+		if err != nil {
+			g.logger.Debug("error fetching announced block", "peer", origin, "hash", hash, "error", err)
+			if !client.IsNotFound(err) {
+				g.node.recordPeerFailure(origin, err)
+			}
+			return false
+		}
+		g.logger.Debug("received announced block", "peer", origin, "hash", hash, "height", block.Header.Number)
+		return g.node.submitPeerBlock(ctx, origin, &block)
+	case InventoryTx:
+		if g.node.HasTx(hash) {
+			return true
+		}
+		tx, err := fetchPendingTx(ctx, g.client, peer, hash)
+		if err != nil {
+			g.logger.Debug("error fetching announced tx", "peer", origin, "hash", hash, "error", err)
+			if !client.IsNotFound(err) {
+				g.node.recordPeerFailure(origin, err)
+			}
+			return false
+		}
+		if _, err := g.node.addPendingTx(tx, origin); err != nil {
+			g.logger.Debug("rejected announced tx", "peer", origin, "hash", hash, "error", err)
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+func (n *Node) handleInventory() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		var message InventoryMessage
+		if err := readJsonRequest(request, &message); err != nil {
+			writeJsonErrorResponse(writer, err, http.StatusBadRequest)
+			return
+		}
+		defer request.Body.Close()
+		if message.Type != InventoryBlock && message.Type != InventoryTx {
+			writeJsonErrorResponse(writer, fmt.Errorf("unknown inventory type '%s'", message.Type), http.StatusBadRequest)
+			return
+		}
+		peer, ok := n.announcer(request, message.From)
+		if !ok {
+			writeJsonErrorResponse(writer, fmt.Errorf("announcements are only accepted from handshaked peers"), http.StatusForbidden)
+			return
+		}
+		if address := peer.SocketAddress(); n.IsPeerBanned(address) {
+			writeJsonErrorResponse(writer, fmt.Errorf("peer %s is banned", address), http.StatusForbidden)
+			return
+		}
+		n.gossip.receive(message.Type, message.Hashes, peer)
+		writeJsonResponse(writer, struct{}{})
+	}
+}
+
+// announcer returns the known peer that sent an announcement. The sender
+// names itself in from, so it is only believed for bootstraps and handshaked
+// peers and when the request comes from that peer's ip. Otherwise any caller
+// could have the node fetch from, and score, a host of its choosing.
+func (n *Node) announcer(request *http.Request, from PeerNode) (PeerNode, bool) {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	address := from.SocketAddress()
+	peer, ok := n.knownPeers[address]
+	if !ok || !n.trustedPeers[address] || peer.IpAddress != clientIp(request) {
+		return PeerNode{}, false
+	}
+	return peer, true
+}
+
+func (n *Node) handleListPendingTxs() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		txs, err := n.PendingTxResponses()
+		if err != nil {
+			writeJsonErrorResponse(writer, err, http.StatusInternalServerError)
+			return
+		}
+		writeJsonResponse(writer, PendingTxsResponse{Txs: txs})
+	}
+}
+
+// PendingTxResponses returns the pending txs with their hashes in the order
+// they arrived.
+func (n *Node) PendingTxResponses() ([]PendingTxResponse, error) {
+	txs := n.PendingTxs()
+	result := make([]PendingTxResponse, 0, len(txs))
+	for _, tx := range txs {
+		hash, err := tx.Hash()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, PendingTxResponse{Hash: hash, Tx: tx})
+	}
+	return result, nil
+}
+
+func (n *Node) handleGetPendingTx() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		hash, err := database.ParseHash(mux.Vars(request)["hash"])
+		if err != nil {
+			writeJsonErrorResponse(writer, err, http.StatusBadRequest)
+			return
+		}
+		tx, ok := n.PendingTx(hash)
+		if !ok {
+			writeJsonErrorResponse(writer, fmt.Errorf("no pending tx with hash %s", hash), http.StatusNotFound)
+			return
+		}
+		writeJsonResponse(writer, PendingTxResponse{Hash: hash, Tx: tx})
+	}
+}
+
+func fetchBlock(ctx context.Context, httpClient *http.Client, peer PeerNode, hash database.Hash) (database.Block, error) {
+	result, err := peerClient(httpClient, peer).Block(ctx, hash.String())
+	if err != nil {
+		return result.Block, errors.Wrapf(err, "error fetching block from %s", peer.SocketAddress())
+	}
+	if computed, err := result.Block.Hash(); err != nil || computed != hash {
+		return result.Block, errors.Wrapf(errInvalidData, "peer %s returned the wrong block for %s", peer.SocketAddress(), hash)
+	}
+	return result.Block, nil
+}
+
+func fetchPendingTx(ctx context.Context, httpClient *http.Client, peer PeerNode, hash database.Hash) (database.Tx, error) {
+	result, err := peerClient(httpClient, peer).PendingTx(ctx, hash)
+	if err != nil {
+		return result.Tx, errors.Wrapf(err, "error fetching tx from %s", peer.SocketAddress())
+	}
+	if computed, err := result.Tx.Hash(); err != nil || computed != hash {
+		return result.Tx, errors.Wrapf(errInvalidData, "peer %s returned the wrong tx for %s", peer.SocketAddress(), hash)
+	}
+	return result.Tx, nil
+}
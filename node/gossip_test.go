@@ -0,0 +1,211 @@
+package node
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kparkins/yarbit/database"
+)
+
+// fakePeer serves the pending txs it holds and counts the fetches. While
+// blocked it holds every fetch until unblock is called.
+type fakePeer struct {
+	server  *httptest.Server
+	peer    PeerNode
+	fetches int32
+	lock    sync.Mutex
+	txs     map[database.Hash]database.Tx
+	blocked chan struct{}
+	once    sync.Once
+}
+
+func newFakePeer(t *testing.T, blocked bool) *fakePeer {
+	t.Helper()
+	fake := &fakePeer{txs: make(map[database.Hash]database.Tx)}
+	if blocked {
+		fake.blocked = make(chan struct{})
+	}
+	fake.server = httptest.NewServer(http.HandlerFunc(fake.serve))
+	t.Cleanup(func() {
+		fake.unblock()
+		fake.server.Close()
+	})
+	host, port, err := net.SplitHostPort(fake.server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	number, err := strconv.ParseUint(port, 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake.peer = PeerNode{IpAddress: host, Port: number, IsActive: true}
+	return fake
+}
+
+func (f *fakePeer) serve(writer http.ResponseWriter, request *http.Request) {
+	prefix := strings.TrimSuffix(ApiRouteGetPendingTx, "{hash}")
+	if !strings.HasPrefix(request.URL.Path, prefix) {
+		writeJsonResponse(writer, struct{}{})
+		return
+	}
+	atomic.AddInt32(&f.fetches, 1)
+	if f.blocked != nil {
+		<-f.blocked
+	}
+	hash, err := database.ParseHash(strings.TrimPrefix(request.URL.Path, prefix))
+	if err != nil {
+		writeJsonErrorResponse(writer, err, http.StatusBadRequest)
+		return
+	}
+	f.lock.Lock()
+	tx, ok := f.txs[hash]
+	f.lock.Unlock()
+	if !ok {
+		writeJsonErrorResponse(writer, fmt.Errorf("no pending tx with hash %s", hash), http.StatusNotFound)
+		return
+	}
+	writeJsonResponse(writer, PendingTxResponse{Hash: hash, Tx: tx})
+}
+
+func (f *fakePeer) add(t *testing.T, tx database.Tx) database.Hash {
+	t.Helper()
+	hash, err := tx.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.txs[hash] = tx
+	return hash
+}
+
+func (f *fakePeer) unblock() {
+	if f.blocked != nil {
+		f.once.Do(func() { close(f.blocked) })
+	}
+}
+
+func (f *fakePeer) fetchCount() int {
+	return int(atomic.LoadInt32(&f.fetches))
+}
+
+func testHash(i int) database.Hash {
+	var hash database.Hash
+	hash[0], hash[1] = byte(i>>8), byte(i)
+	return hash
+}
+
+func TestGossipFetchesAnnouncedHashOnce(t *testing.T) {
+	n := newTestNode(t)
+	fake := newFakePeer(t, true)
+	hash := fake.add(t, database.NewTx("alice", "bob", 1, ""))
+
+	n.gossip.receive(InventoryTx, []database.Hash{hash}, fake.peer)
+	n.gossip.receive(InventoryTx, []database.Hash{hash}, fake.peer)
+	eventually(t, func() bool { return fake.fetchCount() == 1 }, "announced tx was not fetched")
+	fake.unblock()
+	eventually(t, func() bool { return n.HasTx(hash) }, "fetched tx was not added to the mempool")
+
+	n.gossip.receive(InventoryTx, []database.Hash{hash}, fake.peer)
+	time.Sleep(50 * time.Millisecond)
+	if count := fake.fetchCount(); count != 1 {
+		t.Fatalf("expected the tx to be fetched once, got %d fetches", count)
+	}
+}
+
+func TestGossipRetriesFailedFetch(t *testing.T) {
+	n := newTestNode(t)
+	fake := newFakePeer(t, false)
+	hash := testHash(1)
+
+	n.gossip.receive(InventoryTx, []database.Hash{hash}, fake.peer)
+	eventually(t, func() bool { return fake.fetchCount() == 1 }, "announced tx was not fetched")
+	eventually(t, func() bool { return len(n.gossip.fetches) == 0 }, "fetch did not finish")
+
+	n.gossip.receive(InventoryTx, []database.Hash{hash}, fake.peer)
+	eventually(t, func() bool { return fake.fetchCount() == 2 }, "failed fetch was not retried on the next announcement")
+	if score := n.PeerScores()[fake.peer.SocketAddress()]; score.Score != 0 || score.Failures != 0 {
+		t.Fatalf("peer was scored for not having an object: %+v", score)
+	}
+}
+
+func TestGossipLimitsConcurrentFetches(t *testing.T) {
+	n := newTestNode(t)
+	fake := newFakePeer(t, true)
+	hashes := make([]database.Hash, maxGossipFetches+4)
+	for i := range hashes {
+		hashes[i] = testHash(i)
+	}
+
+	n.gossip.receive(InventoryTx, hashes, fake.peer)
+	eventually(t, func() bool { return fake.fetchCount() == maxGossipFetches }, "fetches did not start")
+	time.Sleep(50 * time.Millisecond)
+	if count := fake.fetchCount(); count != maxGossipFetches {
+		t.Fatalf("expected at most %d concurrent fetches, got %d", maxGossipFetches, count)
+	}
+	dropped := hashes[len(hashes)-1]
+	if !n.gossip.seen.claim(dropped) {
+		t.Fatal("dropped announcement cannot be fetched from the next announcer")
+	}
+	n.gossip.seen.release(dropped, false)
+}
+
+func TestInventoryFromUnknownAnnouncerIsDropped(t *testing.T) {
+	cases := []struct {
+		name       string
+		known      bool
+		handshaked bool
+		remoteAddr string
+		status     int
+	}{
+		{name: "unknown", remoteAddr: "127.0.0.1:5000", status: http.StatusForbidden},
+		{name: "not handshaked", known: true, remoteAddr: "127.0.0.1:5000", status: http.StatusForbidden},
+		{name: "other ip", known: true, handshaked: true, remoteAddr: "10.0.0.1:5000", status: http.StatusForbidden},
+		{name: "handshaked", known: true, handshaked: true, remoteAddr: "127.0.0.1:5000", status: http.StatusOK},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			n := newTestNode(t)
+			fake := newFakePeer(t, false)
+			if c.known {
+				n.AddPeer(fake.peer)
+			}
+			if c.handshaked {
+				n.trustPeer(fake.peer.SocketAddress())
+			}
+			content, err := json.Marshal(InventoryMessage{
+				Type:   InventoryTx,
+				Hashes: []database.Hash{testHash(1)},
+				From:   fake.peer,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			request := httptest.NewRequest("POST", ApiRouteInventory, bytes.NewReader(content))
+			request.RemoteAddr = c.remoteAddr
+			recorder := httptest.NewRecorder()
+			n.handleInventory()(recorder, request)
+			if recorder.Code != c.status {
+				t.Fatalf("expected status %d, got %d: %s", c.status, recorder.Code, recorder.Body)
+			}
+			if c.status == http.StatusOK {
+				eventually(t, func() bool { return fake.fetchCount() == 1 }, "announcement of a handshaked peer was not fetched")
+				return
+			}
+			time.Sleep(50 * time.Millisecond)
+			if count := fake.fetchCount(); count != 0 {
+				t.Fatalf("fetched %d times from an address named by an unverified announcer", count)
+			}
+		})
+	}
+}
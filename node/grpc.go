@@ -0,0 +1,345 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kparkins/yarbit/database"
+	"github.com/kparkins/yarbit/yarbitpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	grpcpeer "google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// grpcServer implements the services in yarbitpb on top of the node, mirroring
+// the JSON API.
+type grpcServer struct {
+	yarbitpb.UnimplementedNodeServiceServer
+	yarbitpb.UnimplementedBalanceServiceServer
+	yarbitpb.UnimplementedTxServiceServer
+	yarbitpb.UnimplementedBlockServiceServer
+	node *Node
+}
+
+func (n *Node) newGrpcServer() *grpc.Server {
+	options := make([]grpc.ServerOption, 0)
+	if n.config.Protocol == ProtocolHttps {
+		options = append(options, grpc.Creds(credentials.NewTLS(n.server.TLSConfig)))
+	}
+	options = append(options,
+		grpc.ChainUnaryInterceptor(n.limitGrpcUnary, n.authorizeGrpcUnary),
+		grpc.ChainStreamInterceptor(n.limitGrpcStream, n.authorizeGrpcStream),
+	)
+	server := grpc.NewServer(options...)
+	service := &grpcServer{node: n}
+	yarbitpb.RegisterNodeServiceServer(server, service)
+	yarbitpb.RegisterBalanceServiceServer(server, service)
+	yarbitpb.RegisterTxServiceServer(server, service)
+	yarbitpb.RegisterBlockServiceServer(server, service)
+	return server
+}
+
+func (n *Node) serveGrpc(server *grpc.Server) {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", n.config.GrpcPort))
+	if err != nil {
+		n.logger.Error("error listening for grpc", "error", err)
+		return
+	}
+	n.logger.Info("serving grpc", "ip", n.config.IpAddress, "port", n.config.GrpcPort)
+	if err := server.Serve(listener); err != nil {
+		n.logger.Error("error serving grpc", "error", err)
+	}
+}
+
+// grpcMethodRoles lists the methods that need more than the public role.
+var grpcMethodRoles = map[string]Role{
+	yarbitpb.TxService_ServiceDesc.ServiceName + "/SubmitTx": RoleSubmit,
+}
+
+// grpcMethodClasses assigns methods to a rate limit class like routeClasses
+// does for the JSON API. Unlisted methods are in the status class.
+var grpcMethodClasses = map[string]RateClass{
+	yarbitpb.TxService_ServiceDesc.ServiceName + "/SubmitTx":           RateClassTx,
+	yarbitpb.BlockService_ServiceDesc.ServiceName + "/SubscribeBlocks": RateClassSync,
+}
+
+// limitGrpc applies the per-IP rate limits shared with the JSON API to a
+// gRPC call.
+func (n *Node) limitGrpc(ctx context.Context, method string) error {
+	class, ok := grpcMethodClasses[strings.TrimPrefix(method, "/")]
+	if !ok {
+		class = RateClassStatus
+	}
+	ip := ""
+	if caller, ok := grpcpeer.FromContext(ctx); ok {
+		ip = caller.Addr.String()
+		if host, _, err := net.SplitHostPort(ip); err == nil {
+			ip = host
+		}
+	}
+	if allowed, wait := n.allowRequest(class, ip); !allowed {
+		return status.Errorf(codes.ResourceExhausted, "rate limit exceeded for %s requests, retry in %s", class, wait.Round(time.Millisecond))
+	}
+	return nil
+}
+
+func (n *Node) limitGrpcUnary(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := n.limitGrpc(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, request)
+}
+
+func (n *Node) limitGrpcStream(server interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := n.limitGrpc(stream.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(server, stream)
+}
+
+// authorizeGrpc applies the api keys to a gRPC call, reading the key from
+// the authorization or x-api-key metadata.
+func (n *Node) authorizeGrpc(ctx context.Context, method string) error {
+	if !n.authEnabled() {
+		return nil
+	}
+	role := RolePublic
+	md, _ := metadata.FromIncomingContext(ctx)
+	token := ""
+	if values := md.Get(strings.ToLower(HeaderApiKey)); len(values) > 0 {
+		token = values[0]
+	} else if values := md.Get("authorization"); len(values) > 0 {
+		token = strings.TrimPrefix(values[0], "Bearer ")
+	}
+	if token != "" {
+		key, ok := n.authenticate(token)
+		if !ok {
+			return status.Error(codes.Unauthenticated, "invalid api key")
+		}
+		role = key.Role
+	}
+	required, ok := grpcMethodRoles[strings.TrimPrefix(method, "/")]
+	if ok && !role.Allows(required) {
+		code := codes.PermissionDenied
+		if role == RolePublic {
+			code = codes.Unauthenticated
+		}
+		return status.Errorf(code, "method requires the %s role", required)
+	}
+	return nil
+}
+
+func (n *Node) authorizeGrpcUnary(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := n.authorizeGrpc(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, request)
+}
+
+func (n *Node) authorizeGrpcStream(server interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := n.authorizeGrpc(stream.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(server, stream)
+}
+
+func (s *grpcServer) GetStatus(ctx context.Context, request *yarbitpb.GetStatusRequest) (*yarbitpb.Status, error) {
+	peers := s.node.Peers()
+	addresses := make([]string, 0, len(peers))
+	for address := range peers {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	result := &yarbitpb.Status{
+		BlockHash:   s.node.LatestBlockHash().String(),
+		BlockNumber: s.node.LatestBlockNumber(),
+		Peers:       make([]*yarbitpb.Peer, 0, len(peers)),
+		PendingTxs:  uint64(s.node.PendingTxCount()),
+	}
+	for _, address := range addresses {
+		peer := peers[address]
+		result.Peers = append(result.Peers, &yarbitpb.Peer{
+			IpAddress: peer.IpAddress,
+			Port:      peer.Port,
+			Protocol:  peer.Protocol,
+		})
+	}
+	progress := s.node.SyncProgress()
+	result.Sync = &yarbitpb.SyncProgress{
+		Syncing:        progress.Syncing,
+		Peer:           progress.Peer,
+		StartingHeight: progress.StartingHeight,
+		CurrentHeight:  progress.CurrentHeight,
+		HighestHeight:  progress.HighestHeight,
+	}
+	return result, nil
+}
+
+func (s *grpcServer) balances(height *uint64) (database.Hash, map[database.Account]uint, error) {
+	if height == nil {
+		hash, balances := s.node.LatestBalances()
+		return hash, balances, nil
+	}
+	hash, balances, err := s.node.BalancesAt(*height)
+	if err != nil {
+		return hash, nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return hash, balances, nil
+}
+
+func (s *grpcServer) ListBalances(ctx context.Context, request *yarbitpb.ListBalancesRequest) (*yarbitpb.Balances, error) {
+	var height *uint64
+	if request.Height != nil {
+		height = &request.Height.Value
+	}
+	hash, balances, err := s.balances(height)
+	if err != nil {
+		return nil, err
+	}
+	result := &yarbitpb.Balances{
+		BlockHash: hash.String(),
+		Balances:  make(map[string]uint64, len(balances)),
+	}
+	for account, balance := range balances {
+		result.Balances[string(account)] = uint64(balance)
+	}
+	return result, nil
+}
+
+func (s *grpcServer) GetBalance(ctx context.Context, request *yarbitpb.GetBalanceRequest) (*yarbitpb.Balance, error) {
+	var height *uint64
+	if request.Height != nil {
+		height = &request.Height.Value
+	}
+	hash, balances, err := s.balances(height)
+	if err != nil {
+		return nil, err
+	}
+	account := database.NewAccount(request.Account)
+	return &yarbitpb.Balance{
+		BlockHash: hash.String(),
+		Account:   string(account),
+		Balance:   uint64(balances[account]),
+	}, nil
+}
+
+func (s *grpcServer) SubmitTx(ctx context.Context, request *yarbitpb.SubmitTxRequest) (*yarbitpb.SubmitTxResponse, error) {
+	tx := database.NewTx(
+		database.NewAccount(request.From),
+		database.NewAccount(request.To),
+		uint(request.Value),
+		request.Data,
+	)
+	hash, err := s.node.AddPendingTx(tx)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &yarbitpb.SubmitTxResponse{TxHash: hash.String()}, nil
+}
+
+func (s *grpcServer) GetBlock(ctx context.Context, request *yarbitpb.GetBlockRequest) (*yarbitpb.Block, error) {
+	switch id := request.Id.(type) {
+	case *yarbitpb.GetBlockRequest_Hash:
+		hash, err := database.ParseHash(id.Hash)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		block, err := s.node.BlockByHash(hash)
+		if err != nil {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		return blockToProto(hash, block)
+	case *yarbitpb.GetBlockRequest_Number:
+		hash, block, err := s.node.BlockByNumber(id.Number)
+		if err != nil {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		return blockToProto(hash, block)
+	}
+	return nil, status.Error(codes.InvalidArgument, "a block hash or number is required")
+}
+
+func (s *grpcServer) ListBlocks(ctx context.Context, request *yarbitpb.ListBlocksRequest) (*yarbitpb.ListBlocksResponse, error) {
+	limit := request.Limit
+	if limit == 0 {
+		limit = DefaultBlocksLimit
+	}
+	if limit > MaxBlocksLimit {
+		limit = MaxBlocksLimit
+	}
+	blocks, err := s.node.LatestBlocks(limit)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	result := &yarbitpb.ListBlocksResponse{Blocks: make([]*yarbitpb.Block, 0, len(blocks))}
+	for _, block := range blocks {
+		converted, err := blockToProto(block.Hash, block.Block)
+		if err != nil {
+			return nil, err
+		}
+		result.Blocks = append(result.Blocks, converted)
+	}
+	return result, nil
+}
+
+func (s *grpcServer) SubscribeBlocks(request *yarbitpb.SubscribeBlocksRequest, stream yarbitpb.BlockService_SubscribeBlocksServer) error {
+	events, unsubscribe := s.node.events.Subscribe(EventFilter{
+		Types: map[EventType]bool{EventNewBlock: true},
+	})
+	defer unsubscribe()
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+			data := event.Data.(NewBlockEvent)
+			block, err := blockToProto(data.Hash, data.Block)
+			if err != nil {
+				return err
+			}
+			if err := stream.Send(block); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func blockToProto(hash database.Hash, block database.Block) (*yarbitpb.Block, error) {
+	result := &yarbitpb.Block{
+		Hash: hash.String(),
+		Header: &yarbitpb.BlockHeader{
+			Parent:    block.Header.Parent.String(),
+			Number:    block.Header.Number,
+			Nonce:     block.Header.Nonce,
+			Time:      block.Header.Time,
+			Miner:     string(block.Header.Miner),
+			Signature: block.Header.Signature,
+		},
+		Txs: make([]*yarbitpb.Tx, 0, len(block.Txs)),
+	}
+	for _, tx := range block.Txs {
+		txHash, err := tx.Hash()
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		result.Txs = append(result.Txs, &yarbitpb.Tx{
+			Hash:  txHash.String(),
+			From:  string(tx.From),
+			To:    string(tx.To),
+			Value: uint64(tx.Value),
+			Data:  tx.Data,
+			Time:  tx.Time,
+		})
+	}
+	return result, nil
+}
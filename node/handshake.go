@@ -0,0 +1,152 @@
+package node
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kparkins/yarbit/client"
+	"github.com/pkg/errors"
+)
+
+// ProtocolVersion is bumped whenever nodes of different versions can no
+// longer sync with each other.
+const ProtocolVersion = 1
+
+const nodeIdFile = "node_id"
+const handshakeTimeout = 4 * time.Second
+
+func (n *Node) handleNodeInfo() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		writeJsonResponse(writer, n.NodeInfo())
+	}
+}
+
+// handleAddPeer accepts a handshake from a peer. The peer is only added once
+// it is compatible and reachable at the address it advertises.
+func (n *Node) handleAddPeer() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		var info NodeInfo
+		if err := readJsonRequest(request, &info); err != nil {
+			writeJsonErrorResponse(writer, err, http.StatusBadRequest)
+			return
+		}
+		defer request.Body.Close()
+		if err := n.checkCompatible(info); err != nil {
+			writeJsonErrorResponse(writer, err, http.StatusForbidden)
+			return
+		}
+		address := info.Peer.SocketAddress()
+		if n.IsPeerBanned(address) {
+			writeJsonErrorResponse(writer, fmt.Errorf("peer %s is banned", address), http.StatusForbidden)
+			return
+		}
+		advertised, err := peerClient(n.newHttpClient(handshakeTimeout), info.Peer).NodeInfo(request.Context())
+		if err != nil {
+			writeJsonErrorResponse(writer, errors.Wrap(err, fmt.Sprintf("peer is not reachable at %s", address)), http.StatusBadRequest)
+			return
+		}
+		if advertised.NodeId != info.NodeId {
+			writeJsonErrorResponse(writer, fmt.Errorf("a different node answers at %s", address), http.StatusBadRequest)
+			return
+		}
+		peer := info.Peer
+		peer.IsActive = true
+		peer.IsBootstrap = false
+		n.trustPeer(address)
+		n.recordPeerSuccess(address)
+		n.AddPeer(peer)
+		writeJsonResponse(writer, HandshakeResponse{
+			Success: true,
+			Message: fmt.Sprintf("added %s to known peers", address),
+			Info:    n.NodeInfo(),
+		})
+	}
+}
+
+func (n *Node) NodeInfo() NodeInfo {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	return NodeInfo{
+		NodeId:          n.nodeId,
+		NodeVersion:     n.config.Version,
+		ProtocolVersion: ProtocolVersion,
+		ChainId:         n.state.Genesis().ChainId,
+		GenesisHash:     n.genesisHash,
+		BestHeight:      n.state.LatestBlockNumber(),
+		BestHash:        n.state.LatestBlockHash(),
+		Peer:            n.self(),
+	}
+}
+
+// checkCompatible reports why a node described by info cannot be our peer.
+func (n *Node) checkCompatible(info NodeInfo) error {
+	ours := n.NodeInfo()
+	if info.NodeId == ours.NodeId {
+		return fmt.Errorf("refusing to peer with self")
+	}
+	if info.ProtocolVersion != ours.ProtocolVersion {
+		return fmt.Errorf("incompatible protocol version %d, expected %d", info.ProtocolVersion, ours.ProtocolVersion)
+	}
+	if info.ChainId != ours.ChainId {
+		return fmt.Errorf("chain id mismatch: peer is on '%s', expected '%s'", info.ChainId, ours.ChainId)
+	}
+	if info.GenesisHash != ours.GenesisHash {
+		return fmt.Errorf("genesis hash mismatch: peer has %s, expected %s", info.GenesisHash, ours.GenesisHash)
+	}
+	switch info.Peer.Protocol {
+	case "", ProtocolHttp, ProtocolHttps:
+	default:
+		return fmt.Errorf("unsupported peer protocol '%s'", info.Peer.Protocol)
+	}
+	return nil
+}
+
+// loadNodeId returns the id stored in the data directory, creating a new
+// random one on first start.
+func loadNodeId(dataDir string) (string, error) {
+	path := filepath.Join(dataDir, nodeIdFile)
+	content, err := ioutil.ReadFile(path)
+	if err == nil {
+		return strings.TrimSpace(string(content)), nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	encoded := hex.EncodeToString(id)
+	if err := ioutil.WriteFile(path, []byte(encoded+"\n"), 0644); err != nil {
+		return "", err
+	}
+	return encoded, nil
+}
+
+// joinPeers performs the handshake with the peer at address, returning its
+// node info. It fails if the peer rejects us or is itself incompatible.
+func joinPeers(ctx context.Context, httpClient *http.Client, peer PeerNode, info NodeInfo) (NodeInfo, error) {
+	address := peer.SocketAddress()
+	result, err := peerClient(httpClient, peer).AddPeer(ctx, info)
+	if client.StatusCode(err) != 0 {
+		return result.Info, errors.Wrapf(errInvalidData, "handshake rejected by %s: %v", address, err)
+	}
+	if err != nil {
+		return result.Info, errors.Wrapf(err, "error joining peers from %s", address)
+	}
+	if result.Info.ProtocolVersion != info.ProtocolVersion ||
+		result.Info.ChainId != info.ChainId ||
+		result.Info.GenesisHash != info.GenesisHash {
+		return result.Info, errors.Wrapf(errInvalidData, "peer %s is incompatible: protocol %d, chain '%s', genesis %s",
+			address, result.Info.ProtocolVersion, result.Info.ChainId, result.Info.GenesisHash)
+	}
+	return result.Info, nil
+}
@@ -0,0 +1,263 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/kparkins/yarbit/database"
+	"github.com/pkg/errors"
+)
+
+// syncChunkSize is how many block bodies are requested from a peer at once
+// during headers-first sync.
+const syncChunkSize = 100
+
+type peerStatus struct {
+	peer   PeerNode
+	status StatusResponse
+}
+
+// bodyChunk is a run of consecutive headers whose bodies are downloaded with
+// one request.
+type bodyChunk struct {
+	after    string
+	headers  []HeaderEntry
+	blocks   []database.Block
+	provider string
+}
+
+func (n *Node) handleNodeHeaders() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		after := request.URL.Query().Get(ApiQueryParamAfter)
+		limit, err := parseLimit(request, database.MaxBlocksPerRead, database.MaxBlocksPerRead)
+		if err != nil {
+			writeJsonErrorResponse(writer, err, http.StatusBadRequest)
+			return
+		}
+		blocks, err := n.GetBlocksAfter(after, limit)
+		if err != nil {
+			writeJsonErrorResponse(writer, err, http.StatusInternalServerError)
+			return
+		}
+		result := HeadersResult{Headers: make([]HeaderEntry, 0, len(blocks))}
+		for _, block := range blocks {
+			hash, err := block.Hash()
+			if err != nil {
+				writeJsonErrorResponse(writer, err, http.StatusInternalServerError)
+				return
+			}
+			result.Headers = append(result.Headers, HeaderEntry{Hash: hash, Header: block.Header})
+		}
+		if limit > 0 && uint64(len(result.Headers)) == limit {
+			result.Next = result.Headers[len(result.Headers)-1].Hash.String()
+		}
+		writeJsonResponse(writer, result)
+	}
+}
+
+func (n *Node) SyncProgress() SyncProgress {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	progress := n.syncProgress
+	progress.CurrentHeight = n.state.LatestBlockNumber()
+	return progress
+}
+
+func (n *Node) setSyncProgress(progress SyncProgress) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.syncProgress = progress
+}
+
+// isBehind reports whether a peer with the given status has blocks we lack.
+func (n *Node) isBehind(status StatusResponse) bool {
+	if status.Hash.IsEmpty() {
+		return false
+	}
+	return n.LatestBlockHash().IsEmpty() || status.Number > n.LatestBlockNumber()
+}
+
+// syncHeadersFirst downloads and validates the header chain of the highest
+// peer, then fetches the matching bodies in parallel from every peer that
+// has them. Headers are processed a page at a time so memory stays bounded
+// however far behind the node is.
+func (n *Node) syncHeadersFirst(ctx context.Context, httpClient *http.Client, ahead []peerStatus) {
+	logger := n.config.Logger.Component("sync")
+	best := ahead[0]
+	for _, candidate := range ahead[1:] {
+		if candidate.status.Number > best.status.Number {
+			best = candidate
+		}
+	}
+	bestAddress := best.peer.SocketAddress()
+	n.setSyncProgress(SyncProgress{
+		Syncing:        true,
+		Peer:           bestAddress,
+		StartingHeight: n.LatestBlockNumber(),
+		HighestHeight:  best.status.Number,
+	})
+	defer n.setSyncProgress(SyncProgress{})
+	for {
+		tip := n.LatestBlockHash()
+		after := tip.String()
+		if tip.IsEmpty() {
+			after = database.AfterGenesis
+		}
+		page, err := peerClient(httpClient, best.peer).Headers(ctx, after, database.MaxBlocksPerRead)
+		if err != nil {
+			logger.Warn("error fetching headers", "peer", bestAddress, "error", err)
+			syncErrors.WithLabelValues(bestAddress).Inc()
+			n.recordPeerFailure(bestAddress, err)
+			return
+		}
+		if len(page.Headers) == 0 {
+			return
+		}
+		next := uint64(0)
+		if !tip.IsEmpty() {
+			next = n.LatestBlockNumber() + 1
+		}
+		if err := n.verifyHeaders(tip, next, page.Headers); err != nil {
+			logger.Warn("invalid header chain", "peer", bestAddress, "error", err)
+			n.recordPeerFailure(bestAddress, errors.Wrap(errInvalidData, err.Error()))
+			return
+		}
+		last := page.Headers[len(page.Headers)-1].Header.Number
+		logger.Info("fetched headers", "peer", bestAddress, "count", len(page.Headers), "to", last, "height", best.status.Number)
+		peers := make([]PeerNode, 0, len(ahead))
+		for _, candidate := range ahead {
+			if candidate.status.Number >= last {
+				peers = append(peers, candidate.peer)
+			}
+		}
+		chunks, err := n.downloadBodies(ctx, httpClient, peers, after, page.Headers)
+		if err != nil {
+			logger.Warn("error fetching blocks", "error", err)
+			return
+		}
+		for _, chunk := range chunks {
+			for i := range chunk.blocks {
+				if !n.submitPeerBlock(ctx, chunk.provider, &chunk.blocks[i]) {
+					return
+				}
+			}
+		}
+		if page.Next == "" {
+			return
+		}
+	}
+}
+
+// verifyHeaders checks that headers extend the block with hash parent,
+// starting at number, and that each one passes the consensus engine's header
+// checks.
+func (n *Node) verifyHeaders(parent database.Hash, number uint64, headers []HeaderEntry) error {
+	for _, entry := range headers {
+		if entry.Header.Number != number {
+			return fmt.Errorf("expected header %d, got %d", number, entry.Header.Number)
+		}
+		if entry.Header.Parent != parent {
+			return fmt.Errorf("header %d does not link to parent %s", number, parent)
+		}
+		if err := n.engine.VerifyHeader(entry.Hash, &entry.Header); err != nil {
+			return err
+		}
+		parent = entry.Hash
+		number++
+	}
+	return nil
+}
+
+// downloadBodies fetches the blocks for headers in chunks spread across
+// peers. A peer that fails or returns blocks not matching their headers is
+// penalized and dropped, and its chunks are retried with the others.
+func (n *Node) downloadBodies(ctx context.Context, httpClient *http.Client, peers []PeerNode, after string, headers []HeaderEntry) ([]*bodyChunk, error) {
+	chunks := make([]*bodyChunk, 0, len(headers)/syncChunkSize+1)
+	for start := 0; start < len(headers); start += syncChunkSize {
+		end := start + syncChunkSize
+		if end > len(headers) {
+			end = len(headers)
+		}
+		chunks = append(chunks, &bodyChunk{after: after, headers: headers[start:end]})
+		after = headers[end-1].Hash.String()
+	}
+	for len(peers) > 0 {
+		queue := make(chan *bodyChunk, len(chunks))
+		for _, chunk := range chunks {
+			if chunk.blocks == nil {
+				queue <- chunk
+			}
+		}
+		close(queue)
+		if len(queue) == 0 {
+			return chunks, nil
+		}
+		var lock sync.Mutex
+		var wg sync.WaitGroup
+		failed := make(map[string]bool)
+		for _, peer := range peers {
+			wg.Add(1)
+			go func(peer PeerNode) {
+				defer wg.Done()
+				address := peer.SocketAddress()
+				for chunk := range queue {
+					blocks, err := fetchBodies(ctx, httpClient, peer, chunk)
+					if err != nil {
+						n.logger.Debug("error fetching block bodies", "peer", address, "error", err)
+						syncErrors.WithLabelValues(address).Inc()
+						n.recordPeerFailure(address, err)
+						lock.Lock()
+						failed[address] = true
+						lock.Unlock()
+						return
+					}
+					lock.Lock()
+					chunk.blocks = blocks
+					chunk.provider = address
+					lock.Unlock()
+				}
+			}(peer)
+		}
+		wg.Wait()
+		remaining := make([]PeerNode, 0, len(peers))
+		for _, peer := range peers {
+			if !failed[peer.SocketAddress()] {
+				remaining = append(remaining, peer)
+			}
+		}
+		peers = remaining
+		if ctx.Err() != nil {
+			return chunks, ctx.Err()
+		}
+	}
+	for _, chunk := range chunks {
+		if chunk.blocks == nil {
+			return chunks, fmt.Errorf("no peer could provide blocks after %s", chunk.after)
+		}
+	}
+	return chunks, nil
+}
+
+// fetchBodies downloads the blocks of chunk from peer and checks that each
+// one hashes to the hash its header was announced with.
+func fetchBodies(ctx context.Context, httpClient *http.Client, peer PeerNode, chunk *bodyChunk) ([]database.Block, error) {
+	page, err := peerClient(httpClient, peer).Sync(ctx, chunk.after, uint64(len(chunk.headers)))
+	if err != nil {
+		return nil, err
+	}
+	if len(page.Blocks) != len(chunk.headers) {
+		return nil, errors.Wrapf(errInvalidData, "expected %d blocks after %s, got %d", len(chunk.headers), chunk.after, len(page.Blocks))
+	}
+	for i := range page.Blocks {
+		hash, err := page.Blocks[i].Hash()
+		if err != nil {
+			return nil, err
+		}
+		if hash != chunk.headers[i].Hash {
+			return nil, errors.Wrapf(errInvalidData, "block %d does not match its header", chunk.headers[i].Header.Number)
+		}
+	}
+	return page.Blocks, nil
+}
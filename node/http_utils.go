@@ -7,22 +7,6 @@ import (
 	"net/http"
 )
 
-type ErrorResponse struct {
-	Error string `json:"error"`
-}
-
-func readJsonResponse(response *http.Response, result interface{}) error {
-	content, err := ioutil.ReadAll(response.Body)
-	if err != nil {
-		return errors.Wrap(err, "invalid response body")
-	}
-	defer response.Body.Close()
-	if err := json.Unmarshal(content, result); err != nil {
-		return errors.Wrap(err, "failed to deserialize response body")
-	}
-	return nil
-}
-
 func readJsonRequest(request *http.Request, result interface{}) error {
 	content, err := ioutil.ReadAll(request.Body)
 	if err != nil {
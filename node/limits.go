@@ -0,0 +1,199 @@
+package node
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const DefaultMaxBodyBytes = 1 << 20
+
+// bucketIdleTime is how long a client's bucket is kept after it refilled.
+const bucketIdleTime = 10 * time.Minute
+
+type RateClass string
+
+const (
+	RateClassTx     RateClass = "tx"
+	RateClassSync   RateClass = "sync"
+	RateClassStatus RateClass = "status"
+)
+
+// RateLimit allows Rate requests per second per client IP, with bursts of up
+// to Burst requests. A zero Rate disables the limit.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+type RateLimits map[RateClass]RateLimit
+
+func DefaultRateLimits() RateLimits {
+	return RateLimits{
+		RateClassTx:     {Rate: 10, Burst: 20},
+		RateClassSync:   {Rate: 50, Burst: 100},
+		RateClassStatus: {Rate: 20, Burst: 50},
+	}
+}
+
+// routeClasses assigns routes to a rate limit class. Unlisted routes, such as
+// the explorer and metrics, are not rate limited.
+var routeClasses = map[string]RateClass{
+	ApiRouteAddTx:        RateClassTx,
+	ApiRouteRpc:          RateClassTx,
+	ApiRouteMiningSubmit: RateClassTx,
+	ApiRouteAddPeer:      RateClassSync,
+	ApiRouteSync:         RateClassSync,
+	ApiRouteHeaders:      RateClassSync,
+	ApiRouteInventory:    RateClassSync,
+	ApiRouteGetPendingTx: RateClassSync,
+	ApiRouteStatus:       RateClassStatus,
+	ApiRouteListPeers:    RateClassStatus,
+	ApiRouteNodeInfo:     RateClassStatus,
+	ApiRouteListBalances: RateClassStatus,
+	ApiRouteGetBalance:   RateClassStatus,
+	ApiRouteListBlocks:   RateClassStatus,
+	ApiRouteGetBlock:     RateClassStatus,
+	ApiRouteGetTx:        RateClassStatus,
+	ApiRouteAccountTxs:   RateClassStatus,
+	ApiRouteEvents:       RateClassStatus,
+	ApiRouteMiningWork:   RateClassStatus,
+	ApiRoutePendingTxs:   RateClassStatus,
+}
+
+var rateLimitedRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "yarbit",
+	Subsystem: "http",
+	Name:      "rate_limited_requests_total",
+	Help:      "Number of requests rejected by rate limits per route class.",
+}, []string{"class"})
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter keeps a token bucket per client IP for one route class.
+type rateLimiter struct {
+	limit   RateLimit
+	lock    sync.Mutex
+	buckets map[string]*tokenBucket
+	swept   time.Time
+}
+
+func newRateLimiter(limit RateLimit) *rateLimiter {
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+	return &rateLimiter{
+		limit:   limit,
+		buckets: make(map[string]*tokenBucket),
+		swept:   time.Now(),
+	}
+}
+
+// allow takes a token for client, returning how long to wait for the next
+// one when the bucket is empty.
+func (l *rateLimiter) allow(client string, now time.Time) (bool, time.Duration) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.sweep(now)
+	bucket, ok := l.buckets[client]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(l.limit.Burst), last: now}
+		l.buckets[client] = bucket
+	}
+	bucket.tokens = math.Min(float64(l.limit.Burst), bucket.tokens+now.Sub(bucket.last).Seconds()*l.limit.Rate)
+	bucket.last = now
+	if bucket.tokens < 1 {
+		wait := time.Duration((1 - bucket.tokens) / l.limit.Rate * float64(time.Second))
+		return false, wait
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+// sweep forgets clients that have been idle long enough to have a full
+// bucket again.
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < bucketIdleTime {
+		return
+	}
+	l.swept = now
+	for client, bucket := range l.buckets {
+		if now.Sub(bucket.last) > bucketIdleTime {
+			delete(l.buckets, client)
+		}
+	}
+}
+
+func newRateLimiters(limits RateLimits) map[RateClass]*rateLimiter {
+	limiters := make(map[RateClass]*rateLimiter)
+	for class, limit := range limits {
+		if limit.Rate > 0 {
+			limiters[class] = newRateLimiter(limit)
+		}
+	}
+	return limiters
+}
+
+// allowRequest takes a token of class for the client at ip, returning how
+// long to wait when it is over the limit. Classes without a limit always
+// allow.
+func (n *Node) allowRequest(class RateClass, ip string) (bool, time.Duration) {
+	limiter, ok := n.limiters[class]
+	if !ok {
+		return true, 0
+	}
+	allowed, wait := limiter.allow(ip, time.Now())
+	if !allowed {
+		rateLimitedRequests.WithLabelValues(string(class)).Inc()
+	}
+	return allowed, wait
+}
+
+// limitRequests caps request bodies at MaxBodyBytes and applies the per-IP
+// rate limit of the route's class, answering 429 with Retry-After when a
+// client is over it.
+func (n *Node) limitRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if n.config.MaxBodyBytes > 0 {
+			if request.ContentLength > n.config.MaxBodyBytes {
+				writeJsonErrorResponse(writer, fmt.Errorf("request body exceeds %d bytes", n.config.MaxBodyBytes), http.StatusRequestEntityTooLarge)
+				return
+			}
+			request.Body = http.MaxBytesReader(writer, request.Body, n.config.MaxBodyBytes)
+		}
+		class := requestRateClass(request)
+		if allowed, wait := n.allowRequest(class, clientIp(request)); !allowed {
+			writer.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			writeJsonErrorResponse(writer, fmt.Errorf("rate limit exceeded for %s requests", class), http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(writer, request)
+	})
+}
+
+func requestRateClass(request *http.Request) RateClass {
+	if current := mux.CurrentRoute(request); current != nil {
+		if template, err := current.GetPathTemplate(); err == nil {
+			return routeClasses[template]
+		}
+	}
+	return ""
+}
+
+func clientIp(request *http.Request) string {
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		return request.RemoteAddr
+	}
+	return host
+}
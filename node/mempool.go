@@ -0,0 +1,371 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/kparkins/yarbit/database"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	DefaultMempoolMaxTxs       = 5000
+	DefaultMempoolMaxBytes     = 4 << 20
+	DefaultMempoolMaxPerSender = 64
+	DefaultMempoolTtl          = time.Hour
+)
+
+// mempoolSweepInterval is how often expired txs are removed.
+const mempoolSweepInterval = 30 * time.Second
+
+// MempoolLimits bound the pool of pending txs. Zero fields take the
+// matching default.
+type MempoolLimits struct {
+	MaxTxs       int
+	MaxBytes     int
+	MaxPerSender int
+	// Ttl is how long a tx may wait to be mined, counted from its own
+	// timestamp so that every node expires it at the same time.
+	Ttl time.Duration
+}
+
+func DefaultMempoolLimits() MempoolLimits {
+	return MempoolLimits{
+		MaxTxs:       DefaultMempoolMaxTxs,
+		MaxBytes:     DefaultMempoolMaxBytes,
+		MaxPerSender: DefaultMempoolMaxPerSender,
+		Ttl:          DefaultMempoolTtl,
+	}
+}
+
+func (l MempoolLimits) withDefaults() MempoolLimits {
+	defaults := DefaultMempoolLimits()
+	if l.MaxTxs <= 0 {
+		l.MaxTxs = defaults.MaxTxs
+	}
+	if l.MaxBytes <= 0 {
+		l.MaxBytes = defaults.MaxBytes
+	}
+	if l.MaxPerSender <= 0 {
+		l.MaxPerSender = defaults.MaxPerSender
+	}
+	if l.Ttl <= 0 {
+		l.Ttl = defaults.Ttl
+	}
+	return l
+}
+
+// MempoolReason explains why a tx was rejected by or dropped from the
+// mempool.
+type MempoolReason string
+
+const (
+	// MempoolReasonInvalid txs do not apply on top of the chain and the txs
+	// ahead of them in the pool, e.g. because the sender cannot pay.
+	MempoolReasonInvalid     MempoolReason = "invalid"
+	MempoolReasonTooLarge    MempoolReason = "too_large"
+	MempoolReasonSenderLimit MempoolReason = "sender_limit"
+	// MempoolReasonFull txs were rejected because the pool is full of txs
+	// with a higher priority.
+	MempoolReasonFull MempoolReason = "full"
+	// MempoolReasonEvicted txs made room for a tx with a higher priority.
+	MempoolReasonEvicted MempoolReason = "evicted"
+	MempoolReasonExpired MempoolReason = "expired"
+)
+
+// MempoolError is returned when a tx is not accepted into the mempool.
+type MempoolError struct {
+	Reason MempoolReason
+	Err    error
+}
+
+func (e *MempoolError) Error() string {
+	return fmt.Sprintf("tx rejected (%s): %v", e.Reason, e.Err)
+}
+
+func (e *MempoolError) Unwrap() error {
+	return e.Err
+}
+
+// DroppedTx is a tx that left the mempool without being mined.
+type DroppedTx struct {
+	Hash   database.Hash `json:"tx_hash"`
+	Tx     database.Tx   `json:"tx"`
+	Reason MempoolReason `json:"reason"`
+}
+
+var mempoolRejectedTxs = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "yarbit",
+	Subsystem: "mempool",
+	Name:      "rejected_txs_total",
+	Help:      "Number of txs not accepted into the mempool per reason.",
+}, []string{"reason"})
+
+var mempoolDroppedTxs = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "yarbit",
+	Subsystem: "mempool",
+	Name:      "dropped_txs_total",
+	Help:      "Number of txs removed from the mempool without being mined per reason.",
+}, []string{"reason"})
+
+type mempoolEntry struct {
+	hash    database.Hash
+	tx      database.Tx
+	size    int
+	seq     uint64
+	expires time.Time
+}
+
+// outranks reports whether e has a higher priority than other. Txs moving
+// more value rank higher, and older txs win ties.
+func (e *mempoolEntry) outranks(other *mempoolEntry) bool {
+	if e.tx.Value != other.tx.Value {
+		return e.tx.Value > other.tx.Value
+	}
+	return e.seq < other.seq
+}
+
+// mempool holds the txs waiting to be mined. Txs are kept in the order they
+// arrived, which is the order they are applied in and mined in, so a tx may
+// spend funds received in an earlier pending tx. It is guarded by the node
+// lock.
+type mempool struct {
+	limits  MempoolLimits
+	entries map[database.Hash]*mempoolEntry
+	senders map[database.Account]int
+	bytes   int
+	nextSeq uint64
+	// confirmed is the state of the chain and pending is confirmed with every
+	// entry applied on top.
+	confirmed *database.State
+	pending   *database.State
+}
+
+func newMempool(limits MempoolLimits) *mempool {
+	return &mempool{
+		limits:  limits.withDefaults(),
+		entries: make(map[database.Hash]*mempoolEntry),
+		senders: make(map[database.Account]int),
+	}
+}
+
+// reset revalidates every entry against confirmed, the new state of the
+// chain, dropping those that no longer apply.
+func (m *mempool) reset(confirmed *database.State) []DroppedTx {
+	m.confirmed = confirmed
+	return m.rebuild()
+}
+
+// add validates tx and adds it to the pool, evicting txs with a lower
+// priority if the pool is full. It returns the txs that were dropped to make
+// room.
+func (m *mempool) add(hash database.Hash, tx database.Tx, now time.Time) ([]DroppedTx, error) {
+	content, err := json.Marshal(tx)
+	if err != nil {
+		return nil, err
+	}
+	created := time.Unix(int64(tx.Time), 0)
+	if created.After(now) {
+		created = now
+	}
+	entry := &mempoolEntry{
+		hash:    hash,
+		tx:      tx,
+		size:    len(content),
+		seq:     m.nextSeq,
+		expires: created.Add(m.limits.Ttl),
+	}
+	if !entry.expires.After(now) {
+		return nil, &MempoolError{Reason: MempoolReasonExpired, Err: fmt.Errorf("tx is older than %s", m.limits.Ttl)}
+	}
+	if entry.size > m.limits.MaxBytes {
+		return nil, &MempoolError{Reason: MempoolReasonTooLarge, Err: fmt.Errorf("tx of %d bytes exceeds the mempool size", entry.size)}
+	}
+	if m.senders[tx.From] >= m.limits.MaxPerSender {
+		return nil, &MempoolError{Reason: MempoolReasonSenderLimit, Err: fmt.Errorf("%s already has %d pending txs", tx.From, m.senders[tx.From])}
+	}
+	count, bytes := len(m.entries)+1, m.bytes+entry.size
+	if count <= m.limits.MaxTxs && bytes <= m.limits.MaxBytes {
+		if err := m.pending.ApplyTx(tx); err != nil {
+			return nil, &MempoolError{Reason: MempoolReasonInvalid, Err: err}
+		}
+		m.insert(entry)
+		return nil, nil
+	}
+	// Check the tx before evicting anything for it.
+	if err := m.pending.Clone().ApplyTx(tx); err != nil {
+		return nil, &MempoolError{Reason: MempoolReasonInvalid, Err: err}
+	}
+	victims := make(map[database.Hash]*mempoolEntry)
+	for count > m.limits.MaxTxs || bytes > m.limits.MaxBytes {
+		lowest := m.lowest(victims)
+		if lowest == nil || !entry.outranks(lowest) {
+			return nil, &MempoolError{Reason: MempoolReasonFull, Err: fmt.Errorf("mempool is full")}
+		}
+		victims[lowest.hash] = lowest
+		count, bytes = count-1, bytes-lowest.size
+	}
+	// Txs that spent funds received in a victim no longer apply, possibly
+	// including this one, so replay the pool without the victims before
+	// changing anything.
+	pending := m.confirmed.Clone()
+	invalid := make([]*mempoolEntry, 0)
+	for _, existing := range m.sorted() {
+		if _, ok := victims[existing.hash]; ok {
+			continue
+		}
+		if err := pending.ApplyTx(existing.tx); err != nil {
+			invalid = append(invalid, existing)
+		}
+	}
+	if err := pending.ApplyTx(tx); err != nil {
+		return nil, &MempoolError{Reason: MempoolReasonInvalid, Err: fmt.Errorf("tx depends on an evicted tx")}
+	}
+	dropped := make([]DroppedTx, 0, len(victims)+len(invalid))
+	for _, victim := range victims {
+		m.delete(victim)
+		dropped = append(dropped, DroppedTx{Hash: victim.hash, Tx: victim.tx, Reason: MempoolReasonEvicted})
+	}
+	for _, entry := range invalid {
+		m.delete(entry)
+		dropped = append(dropped, DroppedTx{Hash: entry.hash, Tx: entry.tx, Reason: MempoolReasonInvalid})
+	}
+	m.insert(entry)
+	m.pending = pending
+	return dropped, nil
+}
+
+// remove deletes the tx with hash, e.g. once it is mined, without
+// revalidating the remaining entries.
+func (m *mempool) remove(hash database.Hash) {
+	if entry, ok := m.entries[hash]; ok {
+		m.delete(entry)
+	}
+}
+
+// expire drops every tx that has waited longer than the ttl.
+func (m *mempool) expire(now time.Time) []DroppedTx {
+	dropped := make([]DroppedTx, 0)
+	for _, entry := range m.entries {
+		if !entry.expires.After(now) {
+			m.delete(entry)
+			dropped = append(dropped, DroppedTx{Hash: entry.hash, Tx: entry.tx, Reason: MempoolReasonExpired})
+		}
+	}
+	if len(dropped) == 0 {
+		return dropped
+	}
+	return append(dropped, m.rebuild()...)
+}
+
+func (m *mempool) get(hash database.Hash) (database.Tx, bool) {
+	entry, ok := m.entries[hash]
+	if !ok {
+		return database.Tx{}, false
+	}
+	return entry.tx, true
+}
+
+func (m *mempool) has(hash database.Hash) bool {
+	_, ok := m.entries[hash]
+	return ok
+}
+
+func (m *mempool) len() int {
+	return len(m.entries)
+}
+
+// txs returns the pending txs in the order they arrived.
+func (m *mempool) txs() []database.Tx {
+	entries := m.sorted()
+	txs := make([]database.Tx, 0, len(entries))
+	for _, entry := range entries {
+		txs = append(txs, entry.tx)
+	}
+	return txs
+}
+
+func (m *mempool) sorted() []*mempoolEntry {
+	entries := make([]*mempoolEntry, 0, len(m.entries))
+	for _, entry := range m.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].seq < entries[j].seq
+	})
+	return entries
+}
+
+// rebuild reapplies every entry on top of the confirmed state, dropping the
+// ones that no longer apply.
+func (m *mempool) rebuild() []DroppedTx {
+	dropped := make([]DroppedTx, 0)
+	m.pending = m.confirmed.Clone()
+	for _, entry := range m.sorted() {
+		if err := m.pending.ApplyTx(entry.tx); err != nil {
+			m.delete(entry)
+			dropped = append(dropped, DroppedTx{Hash: entry.hash, Tx: entry.tx, Reason: MempoolReasonInvalid})
+		}
+	}
+	return dropped
+}
+
+// lowest returns the entry with the lowest priority that is not in excluded.
+func (m *mempool) lowest(excluded map[database.Hash]*mempoolEntry) *mempoolEntry {
+	var lowest *mempoolEntry
+	for hash, entry := range m.entries {
+		if _, ok := excluded[hash]; ok {
+			continue
+		}
+		if lowest == nil || lowest.outranks(entry) {
+			lowest = entry
+		}
+	}
+	return lowest
+}
+
+func (m *mempool) insert(entry *mempoolEntry) {
+	m.entries[entry.hash] = entry
+	m.senders[entry.tx.From]++
+	m.bytes += entry.size
+	m.nextSeq++
+}
+
+func (m *mempool) delete(entry *mempoolEntry) {
+	delete(m.entries, entry.hash)
+	m.bytes -= entry.size
+	if m.senders[entry.tx.From]--; m.senders[entry.tx.From] <= 0 {
+		delete(m.senders, entry.tx.From)
+	}
+}
+
+// sweepMempool periodically drops the pending txs that have expired.
+func (n *Node) sweepMempool(ctx context.Context) {
+	ticker := time.NewTicker(mempoolSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			n.lock.Lock()
+			n.reportDroppedTxs(n.mempool.expire(n.now()))
+			n.compactMempoolJournal()
+			n.lock.Unlock()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// reportDroppedTxs logs, counts and publishes the txs dropped from the
+// mempool.
+func (n *Node) reportDroppedTxs(dropped []DroppedTx) {
+	for _, tx := range dropped {
+		n.logger.Info("dropped pending tx", "tx", tx.Hash, "reason", tx.Reason)
+		mempoolDroppedTxs.WithLabelValues(string(tx.Reason)).Inc()
+		n.events.Publish(newDroppedTxEvent(tx))
+	}
+	n.journal.stale += len(dropped)
+}
@@ -0,0 +1,149 @@
+package node
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/kparkins/yarbit/database"
+	"github.com/pkg/errors"
+)
+
+const mempoolJournalFile = "mempool.journal"
+
+// mempoolJournal appends every tx accepted into the mempool to a file in the
+// data directory, one JSON tx per line, so pending txs survive a restart.
+// Txs that leave the pool stay in the file until it is rewritten.
+type mempoolJournal struct {
+	path string
+	file *os.File
+	// stale counts the txs in the file that are no longer pending.
+	stale int
+}
+
+func newMempoolJournal(dataDir string) *mempoolJournal {
+	return &mempoolJournal{path: filepath.Join(dataDir, mempoolJournalFile)}
+}
+
+// load returns the journaled txs. A line that cannot be parsed, e.g. one
+// cut short by a crash, ends the journal.
+func (j *mempoolJournal) load() ([]database.Tx, error) {
+	file, err := os.Open(j.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open mempool journal")
+	}
+	defer file.Close()
+	txs := make([]database.Tx, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var tx database.Tx
+		if err := json.Unmarshal(scanner.Bytes(), &tx); err != nil {
+			break
+		}
+		txs = append(txs, tx)
+	}
+	return txs, nil
+}
+
+func (j *mempoolJournal) append(tx database.Tx) error {
+	if j.file == nil {
+		file, err := os.OpenFile(j.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return errors.Wrap(err, "failed to open mempool journal")
+		}
+		j.file = file
+	}
+	content, err := json.Marshal(tx)
+	if err != nil {
+		return err
+	}
+	_, err = j.file.Write(append(content, '\n'))
+	return err
+}
+
+// rewrite replaces the journal with txs, the txs still pending.
+func (j *mempoolJournal) rewrite(txs []database.Tx) error {
+	temp := j.path + ".tmp"
+	file, err := os.OpenFile(temp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return errors.Wrap(err, "failed to write mempool journal")
+	}
+	writer := bufio.NewWriter(file)
+	for _, tx := range txs {
+		content, err := json.Marshal(tx)
+		if err != nil {
+			file.Close()
+			return err
+		}
+		writer.Write(append(content, '\n'))
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return errors.Wrap(err, "failed to write mempool journal")
+	}
+	if err := file.Close(); err != nil {
+		return errors.Wrap(err, "failed to write mempool journal")
+	}
+	j.close()
+	if err := os.Rename(temp, j.path); err != nil {
+		return errors.Wrap(err, "failed to replace mempool journal")
+	}
+	j.stale = 0
+	return nil
+}
+
+func (j *mempoolJournal) close() {
+	if j.file != nil {
+		j.file.Close()
+		j.file = nil
+	}
+}
+
+// loadMempool re-adds the journaled txs that are not yet in a block and
+// still valid on top of the current chain, then compacts the journal.
+func (n *Node) loadMempool() error {
+	txs, err := n.journal.load()
+	if err != nil {
+		return err
+	}
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	restored, mined := 0, 0
+	for _, tx := range txs {
+		hash, err := tx.Hash()
+		if err != nil {
+			continue
+		}
+		if n.state.HasTx(hash) {
+			mined++
+			continue
+		}
+		if n.mempool.has(hash) {
+			continue
+		}
+		dropped, err := n.mempool.add(hash, tx, n.now())
+		n.reportDroppedTxs(dropped)
+		if err != nil {
+			n.logger.Debug("discarded journaled tx", "tx", hash, "error", err)
+			continue
+		}
+		restored++
+	}
+	n.logger.Info("loaded mempool journal", "restored", restored, "mined", mined, "discarded", len(txs)-restored-mined)
+	return n.journal.rewrite(n.mempool.txs())
+}
+
+// compactMempoolJournal rewrites the journal once txs have left the pool.
+// The caller must hold the node lock.
+func (n *Node) compactMempoolJournal() {
+	if n.journal.stale == 0 {
+		return
+	}
+	if err := n.journal.rewrite(n.mempool.txs()); err != nil {
+		n.logger.Warn("error compacting mempool journal", "error", err)
+	}
+}
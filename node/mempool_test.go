@@ -0,0 +1,120 @@
+package node
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kparkins/yarbit/database"
+)
+
+// newTestMempool returns a mempool with limits on top of a fresh dev chain.
+func newTestMempool(t *testing.T, limits MempoolLimits) *mempool {
+	t.Helper()
+	m := newMempool(limits)
+	m.reset(newTestNode(t).state)
+	return m
+}
+
+// addTx adds tx to m and returns its hash, the dropped txs and the reason it
+// was rejected, if it was.
+func addTx(t *testing.T, m *mempool, tx database.Tx, now time.Time) (database.Hash, []DroppedTx, MempoolReason) {
+	t.Helper()
+	hash, err := tx.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dropped, err := m.add(hash, tx, now)
+	if err == nil {
+		return hash, dropped, ""
+	}
+	var rejected *MempoolError
+	if !errors.As(err, &rejected) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return hash, dropped, rejected.Reason
+}
+
+func TestMempoolEvictsLowestPriority(t *testing.T) {
+	m := newTestMempool(t, MempoolLimits{MaxTxs: 2})
+	now := time.Now()
+	low, _, _ := addTx(t, m, database.NewTx("alice", "bob", 1, ""), now)
+	high, _, _ := addTx(t, m, database.NewTx("carol", "bob", 5, ""), now)
+
+	hash, dropped, reason := addTx(t, m, database.NewTx("dave", "bob", 3, ""), now)
+	if reason != "" {
+		t.Fatalf("tx with a higher priority was rejected (%s)", reason)
+	}
+	if len(dropped) != 1 || dropped[0].Hash != low || dropped[0].Reason != MempoolReasonEvicted {
+		t.Fatalf("expected the lowest priority tx to be evicted, got %+v", dropped)
+	}
+	if !m.has(hash) || !m.has(high) || m.has(low) || m.len() != 2 {
+		t.Fatalf("unexpected pool after eviction: %v", m.txs())
+	}
+
+	if _, _, reason := addTx(t, m, database.NewTx("alice", "bob", 2, ""), now); reason != MempoolReasonFull {
+		t.Fatalf("expected a tx with the lowest priority to be rejected as full, got %q", reason)
+	}
+}
+
+func TestMempoolEvictionIsUndoneWhenTxIsRejected(t *testing.T) {
+	m := newTestMempool(t, MempoolLimits{MaxTxs: 2})
+	now := time.Now()
+	first, _, _ := addTx(t, m, database.NewTx("alice", "erin", 2, ""), now)
+	second, _, _ := addTx(t, m, database.NewTx("bob", "erin", 2, ""), now)
+
+	// Only applies while erin holds the funds of both pending txs, but one of
+	// them has to be evicted to make room for it.
+	_, dropped, reason := addTx(t, m, database.NewTx("erin", "carol", 3, ""), now)
+	if reason != MempoolReasonInvalid {
+		t.Fatalf("expected a tx spending an evicted tx to be rejected as invalid, got %q", reason)
+	}
+	if len(dropped) != 0 || !m.has(first) || !m.has(second) || m.len() != 2 {
+		t.Fatalf("rejected tx changed the pool: dropped %+v, pool %v", dropped, m.txs())
+	}
+	if err := m.pending.Clone().ApplyTx(database.NewTx("erin", "carol", 4, "")); err != nil {
+		t.Fatalf("pending state lost the funds of a tx still in the pool: %v", err)
+	}
+}
+
+func TestMempoolTtl(t *testing.T) {
+	m := newTestMempool(t, MempoolLimits{Ttl: time.Minute})
+	now := time.Now()
+
+	stale := database.NewTx("alice", "bob", 1, "")
+	stale.Time = uint64(now.Add(-2 * time.Minute).Unix())
+	if _, _, reason := addTx(t, m, stale, now); reason != MempoolReasonExpired {
+		t.Fatalf("expected a tx older than the ttl to be rejected as expired, got %q", reason)
+	}
+
+	hash, _, _ := addTx(t, m, database.NewTx("alice", "bob", 1, ""), now)
+	if dropped := m.expire(now.Add(30 * time.Second)); len(dropped) != 0 {
+		t.Fatalf("tx expired before its ttl: %+v", dropped)
+	}
+	dropped := m.expire(now.Add(2 * time.Minute))
+	if len(dropped) != 1 || dropped[0].Hash != hash || dropped[0].Reason != MempoolReasonExpired {
+		t.Fatalf("expected the tx to expire, got %+v", dropped)
+	}
+	if m.len() != 0 {
+		t.Fatalf("expired tx is still pending")
+	}
+}
+
+func TestMempoolSenderLimit(t *testing.T) {
+	m := newTestMempool(t, MempoolLimits{MaxPerSender: 2})
+	now := time.Now()
+	addTx(t, m, database.NewTx("alice", "bob", 1, ""), now)
+	addTx(t, m, database.NewTx("alice", "bob", 2, ""), now)
+
+	if _, _, reason := addTx(t, m, database.NewTx("alice", "bob", 3, ""), now); reason != MempoolReasonSenderLimit {
+		t.Fatalf("expected the sender limit to be enforced, got %q", reason)
+	}
+	if _, _, reason := addTx(t, m, database.NewTx("bob", "alice", 1, ""), now); reason != "" {
+		t.Fatalf("other senders are limited too (%s)", reason)
+	}
+
+	m.remove(m.sorted()[0].hash)
+	if _, _, reason := addTx(t, m, database.NewTx("alice", "bob", 3, ""), now); reason != "" {
+		t.Fatalf("sender is still limited after a tx left the pool (%s)", reason)
+	}
+}
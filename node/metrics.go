@@ -0,0 +1,115 @@
+package node
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/kparkins/yarbit/consensus"
+	"github.com/kparkins/yarbit/database"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var syncErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "yarbit",
+	Subsystem: "sync",
+	Name:      "errors_total",
+	Help:      "Number of failed sync operations per peer.",
+}, []string{"peer"})
+
+var httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "yarbit",
+	Subsystem: "http",
+	Name:      "request_duration_seconds",
+	Help:      "Latency of HTTP requests per route.",
+	Buckets:   prometheus.DefBuckets,
+}, []string{"route", "method", "code"})
+
+func (n *Node) newMetricsRegistry() *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		consensus.MiningAttempts,
+		consensus.MiningHashRate,
+		syncErrors,
+		httpRequestDuration,
+		rateLimitedRequests,
+		mempoolRejectedTxs,
+		mempoolDroppedTxs,
+		database.BlockStoreReadDuration,
+		database.BlockStoreWriteDuration,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: "yarbit",
+			Name:      "block_height",
+			Help:      "Number of the latest block.",
+		}, func() float64 {
+			return float64(n.LatestBlockNumber())
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: "yarbit",
+			Name:      "seconds_since_last_block",
+			Help:      "Seconds elapsed since the latest block was created.",
+		}, func() float64 {
+			last := n.LatestBlockTime()
+			if last == 0 {
+				return 0
+			}
+			return time.Since(time.Unix(int64(last), 0)).Seconds()
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: "yarbit",
+			Name:      "pending_txs",
+			Help:      "Number of transactions waiting to be mined.",
+		}, func() float64 {
+			return float64(n.PendingTxCount())
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: "yarbit",
+			Name:      "known_peers",
+			Help:      "Number of known peers.",
+		}, func() float64 {
+			return float64(n.PeerCount())
+		}),
+	)
+	return registry
+}
+
+func metricsHandler(registry *prometheus.Registry) http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func instrumentRoutes(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: writer, status: http.StatusOK}
+		next.ServeHTTP(recorder, request)
+		route := "unknown"
+		if current := mux.CurrentRoute(request); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+		httpRequestDuration.
+			WithLabelValues(route, request.Method, strconv.Itoa(recorder.status)).
+			Observe(time.Since(start).Seconds())
+	})
+}
@@ -0,0 +1,130 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/kparkins/yarbit/database"
+	"github.com/pkg/errors"
+)
+
+// blockSubmission asks the foreman to add block to the chain, or to seal the
+// pending block itself if block is nil.
+type blockSubmission struct {
+	block  *database.Block
+	result chan submissionResult
+}
+
+type submissionResult struct {
+	block *database.Block
+	err   error
+}
+
+func (n *Node) handleMiningTemplate() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if n.engine.Name() != database.ConsensusProofOfWork {
+			writeJsonErrorResponse(writer, fmt.Errorf("external mining requires proof of work"), http.StatusBadRequest)
+			return
+		}
+		account := n.config.MinerAccount
+		if value := request.URL.Query().Get(ApiQueryParamAccount); value != "" {
+			account = database.NewAccount(value)
+		}
+		block := n.createPendingBlock()
+		block.Header.Miner = account
+		writeJsonResponse(writer, BlockTemplate{
+			Header:     block.Header,
+			Difficulty: database.MiningDifficulty,
+			Target:     database.MiningTarget().String(),
+			Txs:        block.Txs,
+		})
+	}
+}
+
+func (n *Node) handleMiningSubmit() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		var block database.Block
+		if err := readJsonRequest(request, &block); err != nil {
+			writeJsonErrorResponse(writer, err, http.StatusBadRequest)
+			return
+		}
+		defer request.Body.Close()
+		hash, err := block.Hash()
+		if err != nil {
+			writeJsonErrorResponse(writer, err, http.StatusBadRequest)
+			return
+		}
+		if err := n.engine.Verify(&block); err != nil {
+			writeJsonErrorResponse(writer, err, http.StatusBadRequest)
+			return
+		}
+		if err := n.SubmitBlock(request.Context(), &block); err != nil {
+			writeJsonErrorResponse(writer, err, submissionStatus(err))
+			return
+		}
+		writeJsonResponse(writer, SubmitBlockResponse{Hash: hash, Accepted: true})
+	}
+}
+
+// submissionStatus is the http status of a block submission that failed with
+// err: the block was rejected, the foreman did not get to it in time or the
+// node failed to add it.
+func submissionStatus(err error) int {
+	var invalid *database.InvalidBlockError
+	var outOfOrder *database.OutOfOrderBlockError
+	switch {
+	case errors.As(err, &invalid) || errors.As(err, &outOfOrder):
+		return http.StatusBadRequest
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// SubmitBlock hands a block solved elsewhere to the foreman, which adds it to
+// the chain in place of whatever the local miner is working on.
+func (n *Node) SubmitBlock(ctx context.Context, block *database.Block) error {
+	_, err := n.submit(ctx, block)
+	return err
+}
+
+// MineBlock has the foreman seal the pending block right away and returns it
+// once it is added to the chain.
+func (n *Node) MineBlock(ctx context.Context) (*database.Block, error) {
+	return n.submit(ctx, nil)
+}
+
+func (n *Node) submit(ctx context.Context, block *database.Block) (*database.Block, error) {
+	submission := blockSubmission{block: block, result: make(chan submissionResult, 1)}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	select {
+	case n.submitChan <- submission:
+	case <-ctx.Done():
+		return nil, errors.Wrap(ctx.Err(), "node is busy")
+	}
+	select {
+	case result := <-submission.result:
+		return result.block, result.err
+	case <-ctx.Done():
+		return nil, errors.Wrap(ctx.Err(), "node is busy")
+	}
+}
+
+// sealSubmission returns the block of submission, sealing the pending block
+// if it has none.
+func (n *Node) sealSubmission(ctx context.Context, submission blockSubmission) (*database.Block, error) {
+	if submission.block != nil {
+		return submission.block, nil
+	}
+	block, err := n.engine.Seal(ctx, n.createPendingBlock())
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, errors.New("engine did not seal a block")
+	}
+	return block, nil
+}
@@ -0,0 +1,31 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/kparkins/yarbit/database"
+	"github.com/pkg/errors"
+)
+
+func TestSubmissionStatus(t *testing.T) {
+	cases := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{name: "invalid", err: &database.InvalidBlockError{Err: fmt.Errorf("bad seal")}, status: http.StatusBadRequest},
+		{name: "out of order", err: &database.OutOfOrderBlockError{Err: fmt.Errorf("wrong parent")}, status: http.StatusBadRequest},
+		{name: "busy", err: errors.Wrap(context.DeadlineExceeded, "node is busy"), status: http.StatusServiceUnavailable},
+		{name: "internal", err: fmt.Errorf("could not persist new block to data store"), status: http.StatusInternalServerError},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if status := submissionStatus(c.err); status != c.status {
+				t.Fatalf("expected status %d, got %d", c.status, status)
+			}
+		})
+	}
+}
@@ -2,17 +2,23 @@ package node
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"runtime"
+	"strconv"
 	"sync"
 	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/kparkins/yarbit/consensus"
 	"github.com/kparkins/yarbit/database"
+	"github.com/kparkins/yarbit/logging"
 	"github.com/pkg/errors"
+	"google.golang.org/grpc"
 )
 
 type Node struct {
@@ -20,28 +26,71 @@ type Node struct {
 	lock          *sync.RWMutex
 	router        *mux.Router
 	state         *database.State
-	pendingState  *database.State
-	pendingTxs    map[database.Hash]database.Tx
+	mempool       *mempool
+	journal       *mempoolJournal
 	completedTxs  map[database.Hash]database.Tx // TODO need to expire or write to disk periodically
 	knownPeers    map[string]PeerNode
+	peerScores    map[string]PeerScore
+	trustedPeers  map[string]bool // bootstraps and handshaked peers, the only ones sent the peer token
+	limiters      map[RateClass]*rateLimiter
 	server        *http.Server
 	newBlockChan  chan *database.Block
+	submitChan    chan blockSubmission
 	miningAccount database.Account
+	events        *EventBus
+	logger        logging.Logger
+	engine        consensus.Engine
+	mineNow       chan struct{}
+	gossip        *gossip
+	timeOffset    int64
+	nodeId        string
+	genesisHash   database.Hash
+	transport     http.RoundTripper
+	syncProgress  SyncProgress
 }
 
 func New(config Config) *Node {
+	if config.Logger == nil {
+		config.Logger = logging.Default()
+	}
+	if config.SyncInterval <= 0 {
+		config.SyncInterval = DefaultSyncInterval
+	}
+	if config.MaxBodyBytes == 0 {
+		config.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+	if config.RateLimits == nil {
+		config.RateLimits = DefaultRateLimits()
+	}
+	if config.Protocol == "" {
+		config.Protocol = ProtocolHttp
+	}
+	if config.MiningWorkers <= 0 {
+		config.MiningWorkers = runtime.NumCPU()
+	}
 	node := &Node{
 		config:       config,
 		lock:         &sync.RWMutex{},
 		router:       mux.NewRouter(),
-		pendingTxs:   make(map[database.Hash]database.Tx),
+		mempool:      newMempool(config.Mempool),
+		journal:      newMempoolJournal(config.DataDir),
 		completedTxs: make(map[database.Hash]database.Tx),
 		knownPeers:   make(map[string]PeerNode),
+		peerScores:   make(map[string]PeerScore),
+		trustedPeers: make(map[string]bool),
+		limiters:     newRateLimiters(config.RateLimits),
 		server:       &http.Server{},
 		newBlockChan: make(chan *database.Block),
+		submitChan:   make(chan blockSubmission),
+		mineNow:      make(chan struct{}, 1),
+		events:       NewEventBus(),
+		logger:       config.Logger.Component("node"),
 	}
-	if config.Bootstrap.IpAddress != "" {
-		node.knownPeers[config.Bootstrap.SocketAddress()] = config.Bootstrap
+	for _, bootstrap := range config.Bootstraps {
+		if bootstrap.IpAddress != "" {
+			node.knownPeers[bootstrap.SocketAddress()] = bootstrap
+			node.trustedPeers[bootstrap.SocketAddress()] = true
+		}
 	}
 	node.routes()
 	node.server.Addr = fmt.Sprintf(":%d", node.config.Port)
@@ -53,50 +102,175 @@ func (n *Node) routes() {
 	n.router.HandleFunc(ApiRouteAddTx, n.handleAddTx()).Methods("POST")
 	n.router.HandleFunc(ApiRouteAddPeer, n.handleAddPeer()).Methods("POST")
 	n.router.HandleFunc(ApiRouteSync, n.handleNodeSync()).Methods("GET")
+	n.router.HandleFunc(ApiRouteHeaders, n.handleNodeHeaders()).Methods("GET")
 	n.router.HandleFunc(ApiRouteStatus, n.handleNodeStatus()).Methods("GET")
+	n.router.HandleFunc(ApiRouteListPeers, n.handleListPeers()).Methods("GET")
+	n.router.HandleFunc(ApiRouteNodeInfo, n.handleNodeInfo()).Methods("GET")
 	n.router.HandleFunc(ApiRouteListBalances, n.handleListBalances()).Methods("GET")
+	n.router.HandleFunc(ApiRouteGetBalance, n.handleGetBalance()).Methods("GET")
+	n.router.HandleFunc(ApiRouteEvents, n.handleEvents()).Methods("GET")
+	n.router.HandleFunc(ApiRouteListBlocks, n.handleListBlocks()).Methods("GET")
+	n.router.HandleFunc(ApiRouteGetBlock, n.handleGetBlock()).Methods("GET")
+	n.router.HandleFunc(ApiRoutePendingTxs, n.handleListPendingTxs()).Methods("GET")
+	n.router.HandleFunc(ApiRouteGetPendingTx, n.handleGetPendingTx()).Methods("GET")
+	n.router.HandleFunc(ApiRouteGetTx, n.handleGetTx()).Methods("GET")
+	n.router.HandleFunc(ApiRouteInventory, n.handleInventory()).Methods("POST")
+	n.router.HandleFunc(ApiRouteAccountTxs, n.handleAccountTxs()).Methods("GET")
+	n.router.HandleFunc(ApiRouteRpc, n.handleRpc()).Methods("POST")
+	n.router.Handle("/explorer", http.RedirectHandler(ApiRouteExplorer, http.StatusMovedPermanently))
+	n.router.PathPrefix(ApiRouteExplorer).Handler(explorerHandler()).Methods("GET")
+	n.router.HandleFunc(ApiRouteMiningWork, n.handleMiningTemplate()).Methods("GET")
+	n.router.HandleFunc(ApiRouteMiningSubmit, n.handleMiningSubmit()).Methods("POST")
+	if n.config.Dev {
+		n.router.HandleFunc(ApiRouteDevMine, n.handleDevMine()).Methods("POST")
+		n.router.HandleFunc(ApiRouteDevTime, n.handleDevTime()).Methods("POST")
+	}
+	n.router.Handle(ApiRouteMetrics, metricsHandler(n.newMetricsRegistry())).Methods("GET")
+	n.router.Use(instrumentRoutes)
+	n.router.Use(n.limitRequests)
+	n.router.Use(n.authorizeRoutes)
 }
 
 func (n *Node) Run() error {
-	fmt.Print("Loading state from disk...")
-	n.state = database.NewStateFromDisk(n.config.DataDir)
-	if err := n.state.Load(); err != nil {
-		return errors.Wrap(err, "Failed to load state from disk.")
+	if err := n.open(); err != nil {
+		return err
 	}
-	fmt.Print("Complete.\n")
-	n.pendingState = n.state.Clone()
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 	ctx, cancel := context.WithCancel(context.Background())
 	go func() {
-		fmt.Printf("Listening at: %s:%d\n", n.config.IpAddress, n.config.Port)
-		n.server.ListenAndServe()
+		n.logger.Info("listening", "ip", n.config.IpAddress, "port", n.config.Port, "protocol", n.config.Protocol, "mutual_tls", n.config.MutualTls)
+		var err error
+		if n.config.Protocol == ProtocolHttps {
+			err = n.server.ListenAndServeTLS("", "")
+		} else {
+			err = n.server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			n.logger.Error("error serving api", "error", err)
+		}
 	}()
+	var grpcServer *grpc.Server
+	if n.config.GrpcPort > 0 {
+		grpcServer = n.newGrpcServer()
+		go n.serveGrpc(grpcServer)
+	}
 	go n.sync(ctx)
 	go n.startForeman(ctx)
+	go n.sweepMempool(ctx)
 	<-quit
 	cancel()
 	n.server.Shutdown(ctx)
+	if grpcServer != nil {
+		grpcServer.Stop()
+	}
+	if err := n.savePeerStore(); err != nil {
+		n.logger.Warn("error saving peer store", "error", err)
+	}
+	n.lock.Lock()
+	n.compactMempoolJournal()
+	n.journal.close()
+	n.lock.Unlock()
+	return nil
+}
+
+// open loads the chain, peers and pending txs from the data directory and
+// sets up everything the node needs before it starts serving.
+func (n *Node) open() error {
+	n.logger.Info("loading state from disk", "datadir", n.config.DataDir)
+	n.state = database.NewStateFromDisk(n.config.DataDir)
+	n.state.SetLogger(n.config.Logger.Component("state"))
+	if err := n.state.Load(); err != nil {
+		return errors.Wrap(err, "Failed to load state from disk.")
+	}
+	n.logger.Info("loaded state from disk", "height", n.state.LatestBlockNumber(), "hash", n.state.LatestBlockHash())
+	if n.config.Dev && n.state.Genesis().Consensus.Engine != database.ConsensusDev {
+		return errors.Errorf("Dev mode needs a genesis with the %q engine, not %q.", database.ConsensusDev, n.state.Genesis().Consensus.Engine)
+	}
+	engine, err := consensus.New(n.state.Genesis().Consensus, consensus.Options{
+		Account:       n.config.MinerAccount,
+		MiningWorkers: n.config.MiningWorkers,
+		SignerKeyFile: n.config.SignerKeyFile,
+		Chain:         n,
+		Logger:        n.config.Logger.Component("miner"),
+	})
+	if err != nil {
+		return errors.Wrap(err, "Failed to create consensus engine.")
+	}
+	n.engine = engine
+	if n.genesisHash, err = n.state.Genesis().Hash(); err != nil {
+		return errors.Wrap(err, "Failed to hash genesis.")
+	}
+	if n.nodeId, err = loadNodeId(n.config.DataDir); err != nil {
+		return errors.Wrap(err, "Failed to load node id.")
+	}
+	n.logger.Info("using consensus engine", "engine", engine.Name())
+	tlsConfig, err := loadTlsConfig(n.config)
+	if err != nil {
+		return errors.Wrap(err, "Failed to load tls config.")
+	}
+	n.server.TLSConfig = tlsConfig
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	n.transport = transport
+	if n.config.PeerToken != "" {
+		n.transport = &peerAuthTransport{base: transport, token: n.config.PeerToken, trusted: n.isTrustedPeer}
+	}
+	n.gossip = newGossip(n)
+	if err := n.loadPeerStore(); err != nil {
+		n.logger.Warn("ignoring peer store", "error", err)
+	}
+	n.mempool.reset(n.state)
+	if err := n.loadMempool(); err != nil {
+		n.logger.Warn("ignoring mempool journal", "error", err)
+	}
 	return nil
 }
 
 func (n *Node) handleListBalances() http.HandlerFunc {
-	type BalancesListResponse struct {
-		Hash     database.Hash             `json:"block_hash"`
-		Balances map[database.Account]uint `json:"balances"`
+	return func(writer http.ResponseWriter, request *http.Request) {
+		hash, balances, err := n.balancesForRequest(request)
+		if err != nil {
+			writeJsonErrorResponse(writer, err, http.StatusBadRequest)
+			return
+		}
+		writeJsonResponse(writer, BalancesResponse{
+			Hash:     hash,
+			Balances: balances,
+		})
 	}
+}
+
+func (n *Node) handleGetBalance() http.HandlerFunc {
 	return func(writer http.ResponseWriter, request *http.Request) {
-		writeJsonResponse(writer, BalancesListResponse{
-			Hash:     n.LatestBlockHash(),
-			Balances: n.Balances(),
+		hash, balances, err := n.balancesForRequest(request)
+		if err != nil {
+			writeJsonErrorResponse(writer, err, http.StatusBadRequest)
+			return
+		}
+		account := database.NewAccount(mux.Vars(request)["account"])
+		writeJsonResponse(writer, BalanceResponse{
+			Hash:    hash,
+			Account: account,
+			Balance: balances[account],
 		})
 	}
 }
 
-func (n *Node) handleAddTx() http.HandlerFunc {
-	type TxAddResponse struct {
-		Hash database.Hash `json:"tx_hash"`
+func (n *Node) balancesForRequest(request *http.Request) (database.Hash, map[database.Account]uint, error) {
+	height := request.URL.Query().Get(ApiQueryParamHeight)
+	if height == "" {
+		hash, balances := n.LatestBalances()
+		return hash, balances, nil
+	}
+	number, err := strconv.ParseUint(height, 10, 64)
+	if err != nil {
+		return database.Hash{}, nil, errors.Wrap(err, "invalid height")
 	}
+	return n.BalancesAt(number)
+}
+
+func (n *Node) handleAddTx() http.HandlerFunc {
 	return func(writer http.ResponseWriter, request *http.Request) {
 		var txRequest TxAddRequest
 		err := readJsonRequest(request, &txRequest)
@@ -126,45 +300,76 @@ func (n *Node) handleNodeStatus() http.HandlerFunc {
 			Hash:       n.LatestBlockHash(),
 			Number:     n.LatestBlockNumber(),
 			KnownPeers: n.Peers(),
+			PeerScores: n.PeerScores(),
 			PendingTxs: n.PendingTxs(),
+			Sync:       n.SyncProgress(),
 		})
 	}
 }
 
+// handleNodeSync returns a page of at most limit blocks after the given hash,
+// with the cursor to pass as after for the next page. With stream set it
+// instead writes every remaining block as newline delimited JSON.
 func (n *Node) handleNodeSync() http.HandlerFunc {
 	return func(writer http.ResponseWriter, request *http.Request) {
 		after := request.URL.Query().Get(ApiQueryParamAfter)
-		blocks, err := n.GetBlocksAfter(after)
+		if stream, _ := strconv.ParseBool(request.URL.Query().Get(ApiQueryParamStream)); stream {
+			n.streamBlocks(writer, request, after)
+			return
+		}
+		limit, err := parseLimit(request, database.MaxBlocksPerRead, database.MaxBlocksPerRead)
+		if err != nil {
+			writeJsonErrorResponse(writer, err, http.StatusBadRequest)
+			return
+		}
+		blocks, err := n.GetBlocksAfter(after, limit)
 		if err != nil {
 			writeJsonErrorResponse(writer, err, http.StatusInternalServerError)
 			return
 		}
-		writeJsonResponse(writer, SyncResult{Blocks: blocks})
+		result := SyncResult{Blocks: blocks}
+		if limit > 0 && uint64(len(blocks)) == limit {
+			last, err := blocks[len(blocks)-1].Hash()
+			if err != nil {
+				writeJsonErrorResponse(writer, err, http.StatusInternalServerError)
+				return
+			}
+			result.Next = last.String()
+		}
+		writeJsonResponse(writer, result)
 	}
 }
 
-func (n *Node) handleAddPeer() http.HandlerFunc {
-	return func(writer http.ResponseWriter, request *http.Request) {
-		var peer PeerNode
-		if err := readJsonRequest(request, &peer); err != nil {
-			writeJsonErrorResponse(writer, err, http.StatusBadRequest)
+func (n *Node) streamBlocks(writer http.ResponseWriter, request *http.Request, after string) {
+	blocks := make(chan database.Block, syncStreamBatch)
+	go n.state.StreamBlocksAfter(request.Context(), after, blocks)
+	writer.Header().Set("Content-Type", "application/x-ndjson")
+	writer.WriteHeader(http.StatusOK)
+	flusher, _ := writer.(http.Flusher)
+	encoder := json.NewEncoder(writer)
+	for block := range blocks {
+		if err := encoder.Encode(block); err != nil {
+			n.logger.Debug("error streaming blocks", "error", err)
+			for range blocks {
+			}
 			return
 		}
-		n.AddPeer(peer)
-		writeJsonResponse(writer, AddPeerResponse{
-			Success: true,
-			Message: fmt.Sprintf("added %s to known peers", peer.SocketAddress()),
-		})
+		if flusher != nil && len(blocks) == 0 {
+			flusher.Flush()
+		}
 	}
 }
 
 func (n *Node) sync(ctx context.Context) {
-	ticker := time.NewTicker(10 * time.Second)
+	ticker := time.NewTicker(n.config.SyncInterval)
 	c, cancel := context.WithCancel(ctx)
 	for {
 		select {
 		case <-ticker.C:
 			syncWithPeers(c, n)
+			if err := n.savePeerStore(); err != nil {
+				n.logger.Warn("error saving peer store", "error", err)
+			}
 		case <-ctx.Done():
 			cancel()
 			return
@@ -175,30 +380,51 @@ func (n *Node) sync(ctx context.Context) {
 func (n *Node) createPendingBlock() *database.Block {
 	n.lock.RLock()
 	defer n.lock.RUnlock()
-	txs := make([]database.Tx, 0, len(n.pendingTxs))
-	for _, tx := range n.pendingTxs {
-		txs = append(txs, tx)
-	}
-	return &database.Block{
+	txs := n.mempool.txs()
+	block := &database.Block{
 		Header: database.BlockHeader{
 			Parent: n.state.LatestBlockHash(),
 			Number: n.state.NextBlockNumber(),
 			Nonce:  0,
-			Time:   uint64(time.Now().Unix()),
+			Time:   uint64(n.now().Unix()),
 			Miner:  n.config.MinerAccount,
 		},
 		Txs: txs,
 	}
+	if err := n.engine.Prepare(&block.Header); err != nil {
+		n.logger.Error("error preparing pending block", "error", err)
+	}
+	return block
 }
 
 func (n *Node) startMiner(ctx context.Context, minedBlockChan chan<- *database.Block) (bool, context.CancelFunc) {
+	if !n.config.MiningEnabled {
+		return false, func() {}
+	}
 	pendingBlock := n.createPendingBlock()
-	fmt.Printf("pending block: %s\n", pendingBlock.DebugString())
+	n.logger.Debug("pending block", "block", pendingBlock.DebugString())
 	if len(pendingBlock.Txs) <= 0 {
 		return false, func() {}
 	}
 	c, cancelMiner := context.WithCancel(ctx)
-	go mine(c, pendingBlock, minedBlockChan)
+	go func() {
+		block, err := n.engine.Seal(c, pendingBlock)
+		if err == consensus.ErrNotSigner {
+			n.logger.Debug("not sealing block", "height", pendingBlock.Header.Number, "reason", err)
+			return
+		}
+		if err != nil {
+			n.logger.Error("error sealing block", "height", pendingBlock.Header.Number, "error", err)
+			return
+		}
+		if block == nil {
+			return
+		}
+		select {
+		case minedBlockChan <- block:
+		case <-c.Done():
+		}
+	}()
 	return true, cancelMiner
 }
 
@@ -206,10 +432,13 @@ func (n *Node) RemoveTxs(txs []database.Tx) {
 	for _, tx := range txs {
 		hash, err := tx.Hash()
 		if err != nil {
-			fmt.Println(err)
+			n.logger.Error("error hashing tx", "error", err)
 			continue
 		}
-		delete(n.pendingTxs, hash)
+		if n.mempool.has(hash) {
+			n.mempool.remove(hash)
+			n.journal.stale++
+		}
 	}
 }
 
@@ -225,37 +454,91 @@ func (n *Node) startForeman(ctx context.Context) {
 		case block := <-n.newBlockChan:
 			cancelMiner()
 			mining = false
-			hash, err := n.AddBlock(block)
-			if err != nil {
-				fmt.Printf("error adding new block %s : %s\n", hash.String(), err)
+			if err := n.acceptBlock(block); err != nil {
 				break
 			}
-			if err := n.CompleteTxs(block.Txs); err != nil {
-				fmt.Println(err)
-				break
+			mining, cancelMiner = n.startMiner(ctx, n.newBlockChan)
+		case submission := <-n.submitChan:
+			cancelMiner()
+			mining = false
+			block, err := n.sealSubmission(ctx, submission)
+			if err == nil {
+				err = n.acceptBlock(block)
 			}
+			submission.result <- submissionResult{block: block, err: err}
 			mining, cancelMiner = n.startMiner(ctx, n.newBlockChan)
 		case <-ticker.C:
 			if mining {
 				break
 			}
 			mining, cancelMiner = n.startMiner(ctx, n.newBlockChan)
+		case <-n.mineNow:
+			if mining {
+				break
+			}
+			mining, cancelMiner = n.startMiner(ctx, n.newBlockChan)
 		}
 	}
 }
 
+func (n *Node) acceptBlock(block *database.Block) error {
+	hash, err := n.AddBlock(block)
+	if err != nil {
+		n.logger.Error("error adding new block", "hash", hash, "height", block.Header.Number, "error", err)
+		return err
+	}
+	n.events.Publish(newBlockEvent(hash, *block))
+	go n.gossip.announce(InventoryBlock, hash, "")
+	if err := n.CompleteTxs(block.Txs); err != nil {
+		n.logger.Error("error completing txs", "hash", hash, "error", err)
+		return err
+	}
+	return nil
+}
+
+func (n *Node) Events() *EventBus {
+	return n.events
+}
+
 func (n *Node) LatestBlockNumber() uint64 {
 	n.lock.RLock()
 	defer n.lock.RUnlock()
 	return n.state.LatestBlockNumber()
 }
 
+func (n *Node) NextBlockNumber() uint64 {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	return n.state.NextBlockNumber()
+}
+
+func (n *Node) LatestBlockTime() uint64 {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	if n.state.LatestBlockHash().IsEmpty() {
+		return 0
+	}
+	return n.state.LatestBlock().Header.Time
+}
+
 func (n *Node) Balances() map[database.Account]uint {
 	n.lock.RLock()
 	defer n.lock.RUnlock()
 	return n.state.Balances()
 }
 
+func (n *Node) LatestBalances() (database.Hash, map[database.Account]uint) {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	return n.state.LatestBlockHash(), n.state.Balances()
+}
+
+func (n *Node) BalancesAt(height uint64) (database.Hash, map[database.Account]uint, error) {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	return n.state.BalancesAt(height)
+}
+
 func (n *Node) LatestBlockHash() database.Hash {
 	n.lock.RLock()
 	defer n.lock.RUnlock()
@@ -268,6 +551,15 @@ func (n *Node) Protocol() string {
 	return n.config.Protocol
 }
 
+func (n *Node) self() PeerNode {
+	return PeerNode{
+		IpAddress: n.config.IpAddress,
+		Port:      n.config.Port,
+		Protocol:  n.config.Protocol,
+		IsActive:  true,
+	}
+}
+
 func (n *Node) AddPeer(peer PeerNode) bool {
 	n.lock.Lock()
 	defer n.lock.Unlock()
@@ -280,7 +572,14 @@ func (n *Node) AddPeer(peer PeerNode) bool {
 		return false
 	}
 	n.knownPeers[address] = peer
-	fmt.Printf("added new peer %s\n", address)
+	// Count the peer as seen when it is learned, otherwise it would be stale
+	// before it is ever contacted.
+	if score := n.peerScores[address]; score.LastSeen.IsZero() {
+		score.LastSeen = time.Now()
+		n.peerScores[address] = score
+	}
+	n.logger.Info("added new peer", "peer", address)
+	n.events.Publish(newPeerEvent(EventPeerAdded, peer))
 	return true
 }
 
@@ -293,22 +592,48 @@ func (n *Node) AddPeers(peers map[string]PeerNode) {
 func (n *Node) RemovePeer(peer PeerNode) {
 	n.lock.Lock()
 	defer n.lock.Unlock()
-	address := peer.SocketAddress()
+	n.removePeer(peer.SocketAddress())
+}
+
+// removePeer forgets the peer at address. The caller must hold the node lock.
+func (n *Node) removePeer(address string) {
+	peer, ok := n.knownPeers[address]
+	if !ok {
+		return
+	}
 	delete(n.knownPeers, address)
-	fmt.Fprintf(os.Stderr, "removed %s from known peers\n", address)
+	delete(n.peerScores, address)
+	delete(n.trustedPeers, address)
+	n.logger.Warn("removed peer", "peer", address)
+	n.events.Publish(newPeerEvent(EventPeerRemoved, peer))
+}
+
+func (n *Node) PeerCount() int {
+	return len(n.Peers())
 }
 
+// Peers returns the known peers that are not currently banned.
 func (n *Node) Peers() map[string]PeerNode {
 	n.lock.RLock()
 	defer n.lock.RUnlock()
 	peers := make(map[string]PeerNode, len(n.knownPeers))
 	for k, v := range n.knownPeers {
+		if n.isPeerBanned(k) {
+			continue
+		}
 		peers[k] = v
 	}
 	return peers
 }
 
 func (n *Node) AddBlock(block *database.Block) (database.Hash, error) {
+	if err := n.engine.Verify(block); err != nil {
+		var outOfOrder *database.OutOfOrderBlockError
+		if errors.As(err, &outOfOrder) {
+			return database.Hash{}, err
+		}
+		return database.Hash{}, &database.InvalidBlockError{Err: errors.Wrap(err, "invalid seal")}
+	}
 	n.lock.Lock()
 	defer n.lock.Unlock()
 	return n.state.AddBlock(block)
@@ -323,45 +648,85 @@ func (n *Node) CompleteTxs(txs []database.Tx) error {
 			return err
 		}
 		n.completedTxs[hash] = tx
-		delete(n.pendingTxs, hash)
+		if n.mempool.has(hash) {
+			n.mempool.remove(hash)
+			n.journal.stale++
+		}
 	}
+	n.reportDroppedTxs(n.mempool.reset(n.state))
 	return nil
 }
 
 func (n *Node) AddPendingTx(tx database.Tx) (database.Hash, error) {
+	return n.addPendingTx(tx, "")
+}
+
+// addPendingTx adds tx to the pending pool and announces it to every peer
+// except origin, the peer it was received from.
+func (n *Node) addPendingTx(tx database.Tx, origin string) (database.Hash, error) {
 	n.lock.Lock()
 	defer n.lock.Unlock()
 	var hash database.Hash
 	hash, err := tx.Hash()
 	if err != nil {
-		fmt.Printf("error hashing new tx %v\n", tx)
+		n.logger.Error("error hashing new tx", "tx", tx, "error", err)
 		return hash, err
 	}
 	if _, ok := n.completedTxs[hash]; ok {
 		return hash, nil
 	}
-	if _, ok := n.pendingTxs[hash]; ok {
+	// Mined txs are not in completedTxs after a restart.
+	if n.mempool.has(hash) || n.state.HasTx(hash) {
 		return hash, nil
 	}
-	if err := n.pendingState.ApplyTx(tx); err != nil {
-		return hash, err 
+	dropped, err := n.mempool.add(hash, tx, n.now())
+	n.reportDroppedTxs(dropped)
+	if err != nil {
+		var rejected *MempoolError
+		if errors.As(err, &rejected) {
+			mempoolRejectedTxs.WithLabelValues(string(rejected.Reason)).Inc()
+		}
+		return hash, err
+	}
+	if err := n.journal.append(tx); err != nil {
+		n.logger.Warn("error journaling pending tx", "tx", hash, "error", err)
+	}
+	n.events.Publish(newPendingTxEvent(hash, tx))
+	go n.gossip.announce(InventoryTx, hash, origin)
+	if n.config.Dev {
+		n.triggerMining()
 	}
-	n.pendingTxs[hash] = tx
 	return hash, nil
 }
 
+func (n *Node) PendingTx(hash database.Hash) (database.Tx, bool) {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	return n.mempool.get(hash)
+}
+
+func (n *Node) HasTx(hash database.Hash) bool {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	pending := n.mempool.has(hash)
+	_, completed := n.completedTxs[hash]
+	return pending || completed || n.state.HasTx(hash)
+}
+
+func (n *Node) PendingTxCount() int {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	return n.mempool.len()
+}
+
 func (n *Node) PendingTxs() []database.Tx {
 	n.lock.RLock()
 	defer n.lock.RUnlock()
-	txs := make([]database.Tx, 0, len(n.pendingTxs))
-	for _, v := range n.pendingTxs {
-		txs = append(txs, v)
-	}
-	return txs
+	return n.mempool.txs()
 }
 
-func (n *Node) GetBlocksAfter(after string) ([]database.Block, error) {
+func (n *Node) GetBlocksAfter(after string, limit uint64) ([]database.Block, error) {
 	n.lock.RLock()
 	defer n.lock.RUnlock()
-	return n.state.GetBlocksAfter(after)
+	return n.state.GetBlocksAfter(after, limit)
 }
@@ -0,0 +1,46 @@
+package node
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/kparkins/yarbit/database"
+	"github.com/kparkins/yarbit/logging"
+)
+
+// newTestNode returns a node on a fresh dev chain, loaded but not serving.
+func newTestNode(t *testing.T) *Node {
+	t.Helper()
+	dataDir := t.TempDir()
+	if err := database.InitDataDir(dataDir, database.DevGenesis()); err != nil {
+		t.Fatal(err)
+	}
+	n := New(Config{
+		DataDir:   dataDir,
+		IpAddress: "127.0.0.1",
+		Port:      1,
+		Logger:    logging.New(logging.Options{Output: ioutil.Discard}),
+	})
+	if err := n.open(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		n.lock.Lock()
+		defer n.lock.Unlock()
+		n.journal.close()
+	})
+	return n
+}
+
+// eventually fails the test unless condition holds within a second.
+func eventually(t *testing.T, condition func() bool, message string) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal(message)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
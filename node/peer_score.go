@@ -0,0 +1,137 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/kparkins/yarbit/client"
+)
+
+const (
+	peerScoreMax     = 100
+	peerScoreSuccess = 1
+	peerScoreUseful  = 5
+	peerScoreFailure = -5
+	peerScoreInvalid = -20
+
+	// A peer whose score drops to peerBanThreshold is banned for
+	// peerBanDuration, doubling with every further ban up to peerBanMax.
+	peerBanThreshold = -50
+	peerBanDuration  = time.Minute
+	peerBanMax       = 24 * time.Hour
+)
+
+// errInvalidData marks responses from a peer that are malformed or do not
+// match what was requested. It is the client's error for undecodable
+// responses so those are scored the same way.
+var errInvalidData = client.ErrInvalidResponse
+
+func (n *Node) handleListPeers() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		writeJsonResponse(writer, PeersResponse{Peers: n.PeerInfos()})
+	}
+}
+
+// PeerInfos returns every known peer, including banned ones, with its score.
+func (n *Node) PeerInfos() []PeerInfo {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	now := time.Now()
+	peers := make([]PeerInfo, 0, len(n.knownPeers))
+	for address, peer := range n.knownPeers {
+		score := n.peerScores[address]
+		peers = append(peers, PeerInfo{
+			PeerNode: peer,
+			Address:  address,
+			Banned:   score.IsBanned(now),
+			Score:    score,
+		})
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].Address < peers[j].Address
+	})
+	return peers
+}
+
+func (n *Node) PeerScores() map[string]PeerScore {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	scores := make(map[string]PeerScore, len(n.peerScores))
+	for address, score := range n.peerScores {
+		scores[address] = score
+	}
+	return scores
+}
+
+// IsPeerBanned reports whether the peer at address is currently banned.
+func (n *Node) IsPeerBanned(address string) bool {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	return n.isPeerBanned(address)
+}
+
+func (n *Node) isPeerBanned(address string) bool {
+	return n.peerScores[address].IsBanned(time.Now())
+}
+
+func (n *Node) recordPeerSuccess(address string) {
+	n.adjustPeerScore(address, peerScoreSuccess, func(s *PeerScore) {
+		s.LastSeen = time.Now()
+	})
+}
+
+func (n *Node) recordPeerUsefulBlock(address string) {
+	n.adjustPeerScore(address, peerScoreUseful, func(s *PeerScore) {
+		s.UsefulBlocks++
+		s.LastSeen = time.Now()
+	})
+}
+
+// recordPeerFailure penalizes a peer for a failed request. Invalid data is
+// penalized harder than timeouts and other transient failures.
+func (n *Node) recordPeerFailure(address string, err error) {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, errInvalidData):
+		n.adjustPeerScore(address, peerScoreInvalid, func(s *PeerScore) {
+			s.InvalidData++
+		})
+	case errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()):
+		n.adjustPeerScore(address, peerScoreFailure, func(s *PeerScore) {
+			s.Timeouts++
+		})
+	default:
+		n.adjustPeerScore(address, peerScoreFailure, func(s *PeerScore) {
+			s.Failures++
+		})
+	}
+}
+
+func (n *Node) adjustPeerScore(address string, delta int, update func(s *PeerScore)) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	score := n.peerScores[address]
+	update(&score)
+	score.Score += delta
+	if score.Score > peerScoreMax {
+		score.Score = peerScoreMax
+	}
+	if score.Score <= peerBanThreshold {
+		duration := peerBanDuration << score.Bans
+		if duration > peerBanMax || duration <= 0 {
+			duration = peerBanMax
+		}
+		score.Bans++
+		score.BannedUntil = time.Now().Add(duration)
+		score.Score = peerBanThreshold / 2
+		n.logger.Warn("banned peer", "peer", address, "duration", duration, "bans", score.Bans)
+		if peer, ok := n.knownPeers[address]; ok {
+			n.events.Publish(newPeerEvent(EventPeerBanned, peer))
+		}
+	}
+	n.peerScores[address] = score
+}
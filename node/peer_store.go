@@ -0,0 +1,99 @@
+package node
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const peerStoreFile = "peers.json"
+
+// Peers that have not been seen for peerStaleAge are forgotten when the store
+// is saved or loaded. The bootstraps of the current config are always known.
+const peerStaleAge = 7 * 24 * time.Hour
+
+type storedPeer struct {
+	PeerNode
+	Score   PeerScore `json:"score"`
+	Trusted bool      `json:"trusted,omitempty"`
+}
+
+func (p storedPeer) isStale(now time.Time) bool {
+	return now.Sub(p.Score.LastSeen) > peerStaleAge
+}
+
+func (n *Node) peerStorePath() string {
+	return filepath.Join(n.config.DataDir, peerStoreFile)
+}
+
+// loadPeerStore restores the peers known before the last shutdown.
+func (n *Node) loadPeerStore() error {
+	content, err := ioutil.ReadFile(n.peerStorePath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to read peer store")
+	}
+	var stored []storedPeer
+	if err := json.Unmarshal(content, &stored); err != nil {
+		return errors.Wrap(err, "failed to parse peer store")
+	}
+	now := time.Now()
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	loaded := 0
+	for _, peer := range stored {
+		if peer.isStale(now) {
+			continue
+		}
+		// Only the config decides which peers are bootstraps, so a peer
+		// dropped from it ages out like any other.
+		address := peer.SocketAddress()
+		existing, ok := n.knownPeers[address]
+		peer.IsBootstrap = ok && existing.IsBootstrap
+		n.knownPeers[address] = peer.PeerNode
+		n.peerScores[address] = peer.Score
+		if peer.Trusted {
+			n.trustedPeers[address] = true
+		}
+		loaded++
+	}
+	n.logger.Info("loaded peer store", "peers", loaded, "stale", len(stored)-loaded)
+	return nil
+}
+
+// savePeerStore forgets the stale peers that are not bootstraps and writes
+// the remaining known peers, including banned ones, to the data directory.
+func (n *Node) savePeerStore() error {
+	now := time.Now()
+	n.lock.Lock()
+	stored := make([]storedPeer, 0, len(n.knownPeers))
+	for address, peer := range n.knownPeers {
+		entry := storedPeer{PeerNode: peer, Score: n.peerScores[address], Trusted: n.trustedPeers[address]}
+		if !peer.IsBootstrap && entry.isStale(now) {
+			n.removePeer(address)
+			continue
+		}
+		stored = append(stored, entry)
+	}
+	n.lock.Unlock()
+	sort.Slice(stored, func(i, j int) bool {
+		return stored[i].SocketAddress() < stored[j].SocketAddress()
+	})
+	content, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := n.peerStorePath()
+	temp := path + ".tmp"
+	if err := ioutil.WriteFile(temp, content, 0644); err != nil {
+		return errors.Wrap(err, "failed to write peer store")
+	}
+	return os.Rename(temp, path)
+}
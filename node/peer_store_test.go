@@ -0,0 +1,50 @@
+package node
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSavePeerStoreForgetsStalePeers(t *testing.T) {
+	n := newTestNode(t)
+	learned := PeerNode{IpAddress: "127.0.0.2", Port: 8080, IsActive: true}
+	stale := PeerNode{IpAddress: "127.0.0.3", Port: 8080, IsActive: true}
+	bootstrap := PeerNode{IpAddress: "127.0.0.4", Port: 8080, IsActive: true, IsBootstrap: true}
+	for _, peer := range []PeerNode{learned, stale, bootstrap} {
+		n.AddPeer(peer)
+	}
+	if n.PeerScores()[learned.SocketAddress()].LastSeen.IsZero() {
+		t.Fatal("learned peer was never seen")
+	}
+	n.lock.Lock()
+	for _, peer := range []PeerNode{stale, bootstrap} {
+		score := n.peerScores[peer.SocketAddress()]
+		score.LastSeen = time.Now().Add(-2 * peerStaleAge)
+		n.peerScores[peer.SocketAddress()] = score
+	}
+	n.lock.Unlock()
+
+	events, unsubscribe := n.Events().Subscribe(EventFilter{Types: map[EventType]bool{EventPeerRemoved: true}})
+	defer unsubscribe()
+	if err := n.savePeerStore(); err != nil {
+		t.Fatal(err)
+	}
+	peers := n.Peers()
+	if _, ok := peers[learned.SocketAddress()]; !ok {
+		t.Error("recently learned peer was dropped")
+	}
+	if _, ok := peers[stale.SocketAddress()]; ok {
+		t.Error("stale peer is still known")
+	}
+	if _, ok := peers[bootstrap.SocketAddress()]; !ok {
+		t.Error("stale bootstrap was dropped")
+	}
+	select {
+	case event := <-events:
+		if removed := event.Data.(PeerEvent).Peer; removed.SocketAddress() != stale.SocketAddress() {
+			t.Errorf("removal of %s was published instead of the stale peer", removed.SocketAddress())
+		}
+	default:
+		t.Error("removal of the stale peer was not published")
+	}
+}
@@ -0,0 +1,303 @@
+package node
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/kparkins/yarbit/database"
+)
+
+const rpcVersion = "2.0"
+
+// Standard JSON-RPC 2.0 error codes, plus rpcErrServer for requests that were
+// well formed but failed, such as a rejected transaction.
+const (
+	rpcErrParse          = -32700
+	rpcErrInvalidRequest = -32600
+	rpcErrMethodNotFound = -32601
+	rpcErrInvalidParams  = -32602
+	rpcErrInternal       = -32603
+	rpcErrServer         = -32000
+	rpcErrUnauthorized   = -32001
+	rpcErrRateLimited    = -32002
+)
+
+type RpcRequest struct {
+	JsonRpc string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Id      json.RawMessage `json:"id,omitempty"`
+}
+
+type RpcResponse struct {
+	JsonRpc string          `json:"jsonrpc"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *RpcError       `json:"error,omitempty"`
+	Id      json.RawMessage `json:"id"`
+}
+
+type RpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RpcError) Error() string {
+	return fmt.Sprintf("%d: %s", e.Code, e.Message)
+}
+
+type rpcMethod func(params json.RawMessage) (interface{}, *RpcError)
+
+// rpcMethodRoles lists the methods that need more than the public role.
+var rpcMethodRoles = map[string]Role{
+	"yarbit_sendTransaction": RoleSubmit,
+}
+
+type RpcBalance struct {
+	Hash    database.Hash    `json:"block_hash"`
+	Account database.Account `json:"account"`
+	Balance uint             `json:"balance"`
+}
+
+func (n *Node) rpcMethods() map[string]rpcMethod {
+	return map[string]rpcMethod{
+		"yarbit_blockNumber":         n.rpcBlockNumber,
+		"yarbit_getBalance":          n.rpcGetBalance,
+		"yarbit_sendTransaction":     n.rpcSendTransaction,
+		"yarbit_getBlockByNumber":    n.rpcGetBlockByNumber,
+		"yarbit_pendingTransactions": n.rpcPendingTransactions,
+	}
+}
+
+// handleRpc serves JSON-RPC 2.0 requests, either one request object or a
+// batch of them in an array. Notifications, requests without an id, are
+// executed but get no response.
+func (n *Node) handleRpc() http.HandlerFunc {
+	methods := n.rpcMethods()
+	return func(writer http.ResponseWriter, request *http.Request) {
+		role := requestRole(request.Context())
+		content, err := ioutil.ReadAll(request.Body)
+		if err != nil {
+			writeJsonErrorResponse(writer, err, http.StatusBadRequest)
+			return
+		}
+		defer request.Body.Close()
+		content = bytes.TrimSpace(content)
+		if len(content) > 0 && content[0] == '[' {
+			var batch []json.RawMessage
+			if err := json.Unmarshal(content, &batch); err != nil {
+				writeJsonResponse(writer, rpcErrorResponse(nil, rpcErrParse, err.Error()))
+				return
+			}
+			if len(batch) == 0 {
+				writeJsonResponse(writer, rpcErrorResponse(nil, rpcErrInvalidRequest, "empty batch"))
+				return
+			}
+			responses := make([]RpcResponse, 0, len(batch))
+			for i, raw := range batch {
+				// The rate limit middleware charged the first request of
+				// the batch and every further one takes a token as well.
+				if i > 0 {
+					if allowed, _ := n.allowRequest(RateClassTx, clientIp(request)); !allowed {
+						if id := rpcRequestId(raw); id != nil {
+							responses = append(responses, rpcErrorResponse(id, rpcErrRateLimited, "rate limit exceeded for tx requests"))
+						}
+						continue
+					}
+				}
+				if response, ok := n.callRpc(methods, role, raw); ok {
+					responses = append(responses, response)
+				}
+			}
+			if len(responses) == 0 {
+				writer.WriteHeader(http.StatusNoContent)
+				return
+			}
+			writeJsonResponse(writer, responses)
+			return
+		}
+		response, ok := n.callRpc(methods, role, content)
+		if !ok {
+			writer.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeJsonResponse(writer, response)
+	}
+}
+
+// callRpc executes one request, reporting false for notifications.
+func (n *Node) callRpc(methods map[string]rpcMethod, role Role, raw json.RawMessage) (RpcResponse, bool) {
+	if !json.Valid(raw) {
+		return rpcErrorResponse(nil, rpcErrParse, "invalid json"), true
+	}
+	var request RpcRequest
+	if err := json.Unmarshal(raw, &request); err != nil {
+		return rpcErrorResponse(nil, rpcErrInvalidRequest, err.Error()), true
+	}
+	if request.JsonRpc != rpcVersion || request.Method == "" {
+		return rpcErrorResponse(request.Id, rpcErrInvalidRequest, "invalid request"), true
+	}
+	method, ok := methods[request.Method]
+	if !ok {
+		return rpcErrorResponse(request.Id, rpcErrMethodNotFound, fmt.Sprintf("method '%s' not found", request.Method)), request.Id != nil
+	}
+	if required, ok := rpcMethodRoles[request.Method]; ok && !role.Allows(required) {
+		return rpcErrorResponse(request.Id, rpcErrUnauthorized, fmt.Sprintf("method requires the %s role", required)), request.Id != nil
+	}
+	result, rpcErr := method(request.Params)
+	if request.Id == nil {
+		return RpcResponse{}, false
+	}
+	if rpcErr != nil {
+		return RpcResponse{JsonRpc: rpcVersion, Error: rpcErr, Id: request.Id}, true
+	}
+	return RpcResponse{JsonRpc: rpcVersion, Result: result, Id: request.Id}, true
+}
+
+// rpcRequestId returns the id of a request without validating the rest of
+// it, or nil for notifications and malformed requests.
+func rpcRequestId(raw json.RawMessage) json.RawMessage {
+	var request struct {
+		Id json.RawMessage `json:"id"`
+	}
+	if err := json.Unmarshal(raw, &request); err != nil {
+		return nil
+	}
+	return request.Id
+}
+
+func rpcErrorResponse(id json.RawMessage, code int, message string) RpcResponse {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	return RpcResponse{
+		JsonRpc: rpcVersion,
+		Error:   &RpcError{Code: code, Message: message},
+		Id:      id,
+	}
+}
+
+// parseRpcParams reads positional (array) or named (object) params into
+// targets in the order of names. The first required params must be present.
+func parseRpcParams(params json.RawMessage, required int, names []string, targets ...interface{}) *RpcError {
+	values := make([]json.RawMessage, len(names))
+	params = bytes.TrimSpace(params)
+	switch {
+	case len(params) == 0 || bytes.Equal(params, []byte("null")):
+	case params[0] == '[':
+		var positional []json.RawMessage
+		if err := json.Unmarshal(params, &positional); err != nil {
+			return &RpcError{Code: rpcErrInvalidParams, Message: err.Error()}
+		}
+		if len(positional) > len(names) {
+			return &RpcError{Code: rpcErrInvalidParams, Message: fmt.Sprintf("expected at most %d params", len(names))}
+		}
+		copy(values, positional)
+	case params[0] == '{':
+		var named map[string]json.RawMessage
+		if err := json.Unmarshal(params, &named); err != nil {
+			return &RpcError{Code: rpcErrInvalidParams, Message: err.Error()}
+		}
+		for i, name := range names {
+			values[i] = named[name]
+		}
+	default:
+		return &RpcError{Code: rpcErrInvalidParams, Message: "params must be an array or object"}
+	}
+	for i, value := range values {
+		if value == nil {
+			if i < required {
+				return &RpcError{Code: rpcErrInvalidParams, Message: fmt.Sprintf("missing param '%s'", names[i])}
+			}
+			continue
+		}
+		if err := json.Unmarshal(value, targets[i]); err != nil {
+			return &RpcError{Code: rpcErrInvalidParams, Message: fmt.Sprintf("invalid param '%s': %v", names[i], err)}
+		}
+	}
+	return nil
+}
+
+// isBareRpcObject reports whether params is an object without the named
+// param name, which methods taking a single object accept as the object
+// itself.
+func isBareRpcObject(params json.RawMessage, name string) bool {
+	var named map[string]json.RawMessage
+	if err := json.Unmarshal(params, &named); err != nil {
+		return false
+	}
+	_, ok := named[name]
+	return !ok
+}
+
+func (n *Node) rpcBlockNumber(params json.RawMessage) (interface{}, *RpcError) {
+	return n.LatestBlockNumber(), nil
+}
+
+func (n *Node) rpcGetBalance(params json.RawMessage) (interface{}, *RpcError) {
+	var account string
+	var height *uint64
+	if err := parseRpcParams(params, 1, []string{"account", "height"}, &account, &height); err != nil {
+		return nil, err
+	}
+	hash, balances := n.LatestBalances()
+	if height != nil {
+		var err error
+		if hash, balances, err = n.BalancesAt(*height); err != nil {
+			return nil, &RpcError{Code: rpcErrInvalidParams, Message: err.Error()}
+		}
+	}
+	return RpcBalance{
+		Hash:    hash,
+		Account: database.NewAccount(account),
+		Balance: balances[database.NewAccount(account)],
+	}, nil
+}
+
+func (n *Node) rpcSendTransaction(params json.RawMessage) (interface{}, *RpcError) {
+	var txRequest TxAddRequest
+	if isBareRpcObject(params, "tx") {
+		if err := json.Unmarshal(params, &txRequest); err != nil {
+			return nil, &RpcError{Code: rpcErrInvalidParams, Message: err.Error()}
+		}
+	} else if err := parseRpcParams(params, 1, []string{"tx"}, &txRequest); err != nil {
+		return nil, err
+	}
+	tx := database.NewTx(
+		database.NewAccount(txRequest.From),
+		database.NewAccount(txRequest.To),
+		txRequest.Value,
+		txRequest.Data,
+	)
+	hash, err := n.AddPendingTx(tx)
+	if err != nil {
+		return nil, &RpcError{Code: rpcErrServer, Message: err.Error()}
+	}
+	return hash, nil
+}
+
+func (n *Node) rpcGetBlockByNumber(params json.RawMessage) (interface{}, *RpcError) {
+	var number uint64
+	if err := parseRpcParams(params, 1, []string{"number"}, &number); err != nil {
+		return nil, err
+	}
+	hash, block, err := n.BlockByNumber(number)
+	if err != nil {
+		return nil, &RpcError{Code: rpcErrServer, Message: err.Error()}
+	}
+	response, err := NewBlockResponse(hash, block)
+	if err != nil {
+		return nil, &RpcError{Code: rpcErrInternal, Message: err.Error()}
+	}
+	return response, nil
+}
+
+func (n *Node) rpcPendingTransactions(params json.RawMessage) (interface{}, *RpcError) {
+	result, err := n.PendingTxResponses()
+	if err != nil {
+		return nil, &RpcError{Code: rpcErrInternal, Message: err.Error()}
+	}
+	return result, nil
+}
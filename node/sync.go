@@ -1,32 +1,36 @@
 package node
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
-	"os"
 	"time"
 
+	"github.com/kparkins/yarbit/client"
 	"github.com/kparkins/yarbit/database"
 	"github.com/pkg/errors"
 )
 
+// syncStreamBatch is how many blocks a streaming sync reads from disk at a
+// time.
+const syncStreamBatch = 100
+
 func syncWithPeers(ctx context.Context, n *Node) {
+	logger := n.config.Logger.Component("sync")
 	knownPeers := n.Peers()
-	client := &http.Client{
-		Timeout: 4 * time.Second,
-	}
+	httpClient := n.newHttpClient(4 * time.Second)
 	nodeAddress := fmt.Sprintf("%s:%d", n.config.IpAddress, n.config.Port)
+	ahead := make([]peerStatus, 0)
 	for _, peer := range knownPeers {
 		peerAddress := peer.SocketAddress()
-		status, err := fetchPeerStatus(ctx, client, peerAddress)
+		status, err := peerClient(httpClient, peer).Status(ctx)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "%v while checking status of %s\n", err, peer.SocketAddress())
-			n.RemovePeer(peer)
+			logger.Warn("error checking peer status", "peer", peerAddress, "error", err)
+			syncErrors.WithLabelValues(peerAddress).Inc()
+			n.recordPeerFailure(peerAddress, err)
 			continue
 		}
+		n.recordPeerSuccess(peerAddress)
 		status.KnownPeers = FilterPeers(status.KnownPeers, func(s string) bool {
 			return s != nodeAddress
 		})
@@ -34,92 +38,43 @@ func syncWithPeers(ctx context.Context, n *Node) {
 		for _, tx := range status.PendingTxs {
 			hash, err := n.AddPendingTx(tx)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Unable to add TX %v from peer %v: %v", hash, peerAddress, err)
+				logger.Debug("unable to add tx from peer", "tx", hash, "peer", peerAddress, "error", err)
 			}
 		}
-		if err := joinPeers(ctx, client, peerAddress, n.config.IpAddress, n.config.Port); err != nil {
-			fmt.Fprintln(os.Stderr, err)
+		if _, err := joinPeers(ctx, httpClient, peer, n.NodeInfo()); err != nil {
+			logger.Warn("error joining peer", "peer", peerAddress, "error", err)
+			syncErrors.WithLabelValues(peerAddress).Inc()
+			n.recordPeerFailure(peerAddress, err)
 			continue
 		}
-		if status.Number < n.LatestBlockNumber() {
-			continue
+		n.trustPeer(peerAddress)
+		if n.isBehind(status) {
+			ahead = append(ahead, peerStatus{peer: peer, status: status})
 		}
-		if status.Number == 0 && !n.LatestBlockHash().IsEmpty() {
-			continue
-		}
-		blocks, err := fetchBlocks(ctx, client, peerAddress, n.LatestBlockHash())
-		if err != nil {
-			fmt.Fprintln(os.Stderr, err)
-			continue
-		}
-		for i := range blocks {
-			n.newBlockChan <- &blocks[i]
-		}
-
 	}
-
-}
-
-func fetchPeerStatus(ctx context.Context, client *http.Client, address string) (StatusResponse, error) {
-	var status StatusResponse
-	url := fmt.Sprintf("%s://%s%s", "http", address, ApiRouteStatus)
-	request, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return status, errors.Wrap(err, "while creating request")
+	if len(ahead) > 0 {
+		n.syncHeadersFirst(ctx, httpClient, ahead)
 	}
-	response, err := client.Do(request)
-	if err != nil {
-		return status, errors.Wrap(err, fmt.Sprintf("error fetching peers from %s", address))
-	}
-	statusResponse := StatusResponse{}
-	if err := readJsonResponse(response, &statusResponse); err != nil {
-		return status, err
-	}
-	return statusResponse, nil
 }
 
-func joinPeers(ctx context.Context, client *http.Client, address, ip string, port uint64) error {
-	url := fmt.Sprintf("%s://%s%s", "http", address, ApiRouteAddPeer)
-	body, err := json.Marshal(PeerNode{IpAddress: ip, Port: port, IsActive: true})
-	if err != nil {
-		return fmt.Errorf("error marshaling add peer request body")
-	}
-	request, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
-	if err != nil {
-		return errors.Wrap(err, "while creating request")
+// submitPeerBlock adds a block received from a peer, crediting the peer for
+// useful blocks and penalizing it for invalid ones. It reports whether the
+// block was added.
+func (n *Node) submitPeerBlock(ctx context.Context, address string, block *database.Block) bool {
+	err := n.SubmitBlock(ctx, block)
+	var invalid *database.InvalidBlockError
+	switch {
+	case err == nil:
+		n.recordPeerUsefulBlock(address)
+		return true
+	case errors.As(err, &invalid):
+		n.recordPeerFailure(address, errors.Wrap(errInvalidData, err.Error()))
 	}
-	request.Header.Set("Content-Type", "application/json")
-	response, err := client.Do(request)
-	if err != nil {
-		return errors.Wrap(err, fmt.Sprintf("error joining peers from %s", address))
-	}
-	_ = response.Body.Close()
-	return nil
+	return false
 }
 
-func fetchBlocks(ctx context.Context, client *http.Client, address string, hash database.Hash) ([]database.Block, error) {
-	var result SyncResult
-	url := fmt.Sprintf("%s://%s%s", "http", address, ApiRouteSync)
-
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return result.Blocks, errors.Wrap(err, "while creating request")
-	}
-	query := req.URL.Query()
-	query.Set(ApiQueryParamAfter, hash.String())
-	req.URL.RawQuery = query.Encode()
-
-	response, err := client.Do(req)
-	if err != nil {
-		return result.Blocks, errors.Wrap(err, fmt.Sprintf("error fetching blocks from %s", address))
-	}
-	defer response.Body.Close()
-
-	if err := readJsonResponse(response, &result); err != nil {
-		return result.Blocks, errors.Wrap(err, "error reading blocks in response")
-	}
-	if len(result.Blocks) > 0 {
-		fmt.Printf("New blocks %v\n", result.Blocks)
-	}
-	return result.Blocks, nil
-}
\ No newline at end of file
+// peerClient returns an api client for peer that sends its requests with
+// httpClient. Failed requests are not retried; the peer is scored instead.
+func peerClient(httpClient *http.Client, peer PeerNode) *client.Client {
+	return client.ForPeer(peer, client.Options{HttpClient: httpClient})
+}
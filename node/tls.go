@@ -0,0 +1,61 @@
+package node
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// loadTlsConfig builds the TLS settings shared by the API server and the
+// clients used to reach peers. It returns nil when no TLS files are set.
+func loadTlsConfig(config Config) (*tls.Config, error) {
+	if config.TlsCertFile == "" && config.TlsKeyFile == "" && config.TlsCaFile == "" {
+		if config.Protocol == ProtocolHttps || config.MutualTls {
+			return nil, fmt.Errorf("tls requires a certificate and key")
+		}
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if config.TlsCertFile != "" || config.TlsKeyFile != "" {
+		certificate, err := tls.LoadX509KeyPair(config.TlsCertFile, config.TlsKeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load tls certificate")
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	} else if config.Protocol == ProtocolHttps || config.MutualTls {
+		return nil, fmt.Errorf("tls requires a certificate and key")
+	}
+	if config.TlsCaFile != "" {
+		content, err := ioutil.ReadFile(config.TlsCaFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read tls ca")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(content) {
+			return nil, fmt.Errorf("no certificates found in tls ca %s", config.TlsCaFile)
+		}
+		tlsConfig.RootCAs = pool
+		tlsConfig.ClientCAs = pool
+	}
+	if config.MutualTls {
+		if tlsConfig.ClientCAs == nil {
+			return nil, fmt.Errorf("mutual tls requires a ca to verify peers against")
+		}
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+// newHttpClient returns a client for talking to peers. Clients share the
+// node's transport so they present the node certificate under mutual tls.
+func (n *Node) newHttpClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: n.transport,
+	}
+}